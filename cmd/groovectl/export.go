@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// runExport walks the prefix tree writing every pending task as a line of json. The export is not a
+// consistent snapshot, tasks enqueued or dequeued while it runs may or may not be included
+func runExport(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("export")
+	output := fs.String("o", "-", "file to write to, - for stdout")
+	locked := fs.Bool("locked", false, "also export tasks that are currently being processed")
+	_ = fs.Parse(args)
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected at most one prefix, got %d", fs.NArg())
+	}
+
+	var w io.Writer = os.Stdout
+
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		w = f
+	}
+
+	bw := bufio.NewWriter(w)
+
+	e := &exporter{client: c, enc: json.NewEncoder(bw), locked: *locked}
+
+	err := e.export(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	err = bw.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d task(s)\n", e.count)
+
+	return nil
+}
+
+type exporter struct {
+	client *groove.Client
+	enc    *json.Encoder
+	locked bool
+	count  int
+}
+
+// export writes the tasks in the container at prefix, then those of each child container
+func (e *exporter) export(ctx context.Context, prefix string) error {
+	// The root container never holds tasks of its own
+	if prefix != "" {
+		input := groove.BrowseInput{Prefix: prefix}
+
+		for {
+			res, err := e.client.BrowseTasks(ctx, input)
+			if err != nil {
+				return err
+			}
+
+			if e.locked && res.LockedTask != nil {
+				res.Tasks = append([]groove.Task{*res.LockedTask}, res.Tasks...)
+			}
+
+			for _, t := range res.Tasks {
+				err = e.enc.Encode(t)
+				if err != nil {
+					return err
+				}
+
+				e.count++
+			}
+
+			if res.NextCursor == "" {
+				break
+			}
+
+			input.Cursor = res.NextCursor
+		}
+	}
+
+	input := groove.BrowseInput{Prefix: prefix}
+
+	for {
+		res, err := e.client.BrowsePrefixes(ctx, input)
+		if err != nil {
+			return err
+		}
+
+		for _, child := range res.Prefixes {
+			err = e.export(ctx, child.Prefix)
+			if err != nil {
+				return err
+			}
+		}
+
+		if res.NextCursor == "" {
+			return nil
+		}
+
+		input.Cursor = res.NextCursor
+	}
+}
+
+func runImport(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("import")
+	chunk := fs.Int("chunk", maxChunk, "number of tasks sent per request")
+	_ = fs.Parse(args)
+
+	if err := exactArgs(fs, 1); err != nil {
+		return err
+	}
+
+	tasks, err := readTasks(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	imported, err := enqueueChunks(ctx, c, tasks, *chunk)
+	fmt.Fprintf(os.Stderr, "imported %d task(s)\n", imported)
+
+	return err
+}
@@ -0,0 +1,159 @@
+// groovectl is a command line tool for working with a groove server
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// command is a groovectl subcommand, run is given the arguments that follow the subcommand name
+type command struct {
+	usage string
+	help  string
+	run   func(ctx context.Context, c *groove.Client, args []string) error
+}
+
+// commands is filled in by init, as the commands refer back to it for their usage
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"enqueue": {"enqueue [-wait] [-chunk n] <file|->", "Enqueue tasks from a JSON array or NDJSON file", runEnqueue},
+		"dequeue": {"dequeue [-n count] [-timeout duration] <prefix>", "Dequeue a task set, printing it as JSON", runDequeue},
+		"ack":     {"ack [-task id] [-result json] <task set id>", "Acknowledge a task set, or a single task in it", runAck},
+		"nack":    {"nack [-task id] [-error message] <task set id>", "Fail a task set, or a single task in it", runNack},
+		"cancel":  {"cancel <task id>...", "Remove pending tasks from the queue", runCancel},
+		"peek":    {"peek [-n count] [-data] <prefix>", "Show the next tasks to be dequeued from a prefix without dequeuing them", runPeek},
+		"browse":  {"browse [prefix]", "List the prefixes directly below a prefix", runBrowse},
+		"stats":   {"stats [-depth n] [prefix]", "Show statistics for a prefix and its descendants", runStats},
+		"pause":   {"pause <prefix>", "Stop tasks under a prefix from being dequeued", runPause},
+		"resume":  {"resume <prefix>", "Allow tasks under a paused prefix to be dequeued again", runResume},
+		"paused":  {"paused", "List the paused prefixes", runPaused},
+		"purge":   {"purge <prefix>", "Remove every pending task under a prefix", runPurge},
+		"quota":   {"quota", "Show the quota of the namespace and how much of it is used", runQuota},
+		"config":  {"config", "Show the settings that groove is using", runConfig},
+		"export":  {"export [-o file] [-locked] [prefix]", "Write every pending task under a prefix as NDJSON", runExport},
+		"import":  {"import [-chunk n] <file|->", "Enqueue tasks from an NDJSON export", runImport},
+
+		"policies":      {"policies", "List the policy of every prefix", runPolicies},
+		"policy":        {"policy <prefix>", "Show the policy of a prefix and the settings its tasks inherit", runPolicy},
+		"set-policy":    {"set-policy <prefix> <json>", "Set the policy of a prefix, replacing any policy it had", runSetPolicy},
+		"remove-policy": {"remove-policy <prefix>", "Remove the policy of a prefix", runRemovePolicy},
+	}
+}
+
+func main() {
+	defaultURL := os.Getenv("GROOVE_URL")
+	if defaultURL == "" {
+		defaultURL = "http://localhost:9854"
+	}
+
+	flag.Usage = usage
+	baseURL := flag.String("url", defaultURL, "groove server url, defaults to $GROOVE_URL")
+	apiKey := flag.String("key", os.Getenv("GROOVE_API_KEY"), "api key secret, defaults to $GROOVE_API_KEY")
+	keyID := flag.String("key-id", os.Getenv("GROOVE_API_KEY_ID"), "sign requests with the api key instead of sending it, defaults to $GROOVE_API_KEY_ID")
+	namespace := flag.String("namespace", os.Getenv("GROOVE_NAMESPACE"), "namespace to act on, defaults to $GROOVE_NAMESPACE")
+	caFile := flag.String("ca", os.Getenv("GROOVE_CA_FILE"), "file of CAs to trust in place of the system CAs, defaults to $GROOVE_CA_FILE")
+	certFile := flag.String("cert", os.Getenv("GROOVE_CERT_FILE"), "client certificate for mutual tls, defaults to $GROOVE_CERT_FILE")
+	keyFile := flag.String("cert-key", os.Getenv("GROOVE_CERT_KEY_FILE"), "key of the client certificate, defaults to $GROOVE_CERT_KEY_FILE")
+	timeout := flag.Duration("timeout", 50*time.Second, "how long a request can take, including waiting on enqueued tasks")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "groovectl: unknown command %q\n\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	opts := []groove.Option{groove.WithTimeout(*timeout)}
+
+	if *caFile != "" || *certFile != "" {
+		tlsConfig, err := groove.LoadTLSConfig(*caFile, *certFile, *keyFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "groovectl: %s\n", err)
+			os.Exit(2)
+		}
+
+		opts = append(opts, groove.WithTLSConfig(tlsConfig))
+	}
+
+	if *namespace != "" {
+		opts = append(opts, groove.WithNamespace(*namespace))
+	}
+
+	if *keyID != "" {
+		opts = append(opts, groove.WithSignedRequests(*keyID, *apiKey))
+	} else if *apiKey != "" {
+		opts = append(opts, groove.WithAPIKey(*apiKey))
+	}
+
+	err := cmd.run(ctx, groove.New(strings.TrimSuffix(*baseURL, "/"), opts...), flag.Args()[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "groovectl %s: %s\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: groovectl [-url url] [-key secret] [-key-id id] [-namespace name] [-ca file] [-cert file -cert-key file] [-timeout duration] <command> [arguments]\n\ncommands:\n")
+
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-50s %s\n", commands[name].usage, commands[name].help)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nrun groovectl <command> -h for the flags of a command\n")
+}
+
+// newFlagSet creates the flag set for a subcommand, printing the subcommand usage on errors
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: groovectl %s\n", commands[name].usage)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// exactArgs checks that a subcommand was given n positional arguments
+func exactArgs(fs *flag.FlagSet, n int) error {
+	if fs.NArg() != n {
+		fs.Usage()
+		return fmt.Errorf("expected %d argument(s), got %d", n, fs.NArg())
+	}
+
+	return nil
+}
+
+// printJSON writes v to stdout as indented json
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+	"github.com/datomar-labs-inc/groove/groovetest"
+)
+
+func TestCommandArgs(t *testing.T) {
+	// Nothing listens on this address, every command must fail before making a request
+	c := groove.New("http://127.0.0.1:1")
+
+	tasks := filepath.Join(t.TempDir(), "tasks.ndjson")
+
+	err := os.WriteFile(tasks, []byte(`{"id": "a.b.1"}`), 0600)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"enqueue"}, "expected 1 argument(s), got 0"},
+		{[]string{"enqueue", tasks, tasks}, "expected 1 argument(s), got 2"},
+		{[]string{"enqueue", "-chunk", "0", tasks}, "chunk must be between 1 and 1000"},
+		{[]string{"dequeue", "-n", "2"}, "expected 1 argument(s), got 0"},
+		{[]string{"ack", "-task", "a.b.1"}, "expected 1 argument(s), got 0"},
+		{[]string{"ack", "-result", "{", "set"}, "invalid result"},
+		{[]string{"nack", "a", "b"}, "expected 1 argument(s), got 2"},
+		{[]string{"cancel"}, "expected at least one task id"},
+		{[]string{"peek"}, "expected 1 argument(s), got 0"},
+		{[]string{"browse", "a", "b"}, "expected at most one prefix, got 2"},
+		{[]string{"stats", "-depth", "2", "a", "b"}, "expected at most one prefix, got 2"},
+		{[]string{"pause"}, "expected 1 argument(s), got 0"},
+		{[]string{"resume", "a", "b"}, "expected 1 argument(s), got 2"},
+		{[]string{"purge"}, "expected 1 argument(s), got 0"},
+		{[]string{"export", "a", "b"}, "expected at most one prefix, got 2"},
+		{[]string{"import"}, "expected 1 argument(s), got 0"},
+		{[]string{"import", "-chunk", "1001", tasks}, "chunk must be between 1 and 1000"},
+		{[]string{"import", filepath.Join(t.TempDir(), "missing.ndjson")}, "no such file"},
+	}
+
+	for _, test := range tests {
+		err := commands[test.args[0]].run(context.Background(), c, test.args[1:])
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("groovectl %s: expected an error containing %q, got %v", strings.Join(test.args, " "), test.err, err)
+		}
+	}
+}
+
+func TestReadTasks(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		ids   []string
+		err   string
+	}{
+		{"array", `[{"id": "a.1"}, {"id": "a.2"}]`, []string{"a.1", "a.2"}, ""},
+		{"ndjson", "{\"id\": \"a.1\"}\n{\"id\": \"a.2\"}\n", []string{"a.1", "a.2"}, ""},
+		{"leading whitespace", "\n  \t[{\"id\": \"a.1\"}]", []string{"a.1"}, ""},
+		{"empty", " \n", nil, "no tasks to enqueue"},
+		{"bad line", "{\"id\": \"a.1\"}\n{\"id\": ", nil, "task 2"},
+	}
+
+	dir := t.TempDir()
+
+	for i, test := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("%d.json", i))
+
+		err := os.WriteFile(path, []byte(test.input), 0600)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		tasks, err := readTasks(path)
+
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", test.name, test.err, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		var ids []string
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+
+		if strings.Join(ids, ",") != strings.Join(test.ids, ",") {
+			t.Errorf("%s: expected tasks %v, got %v", test.name, test.ids, ids)
+		}
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	src := groovetest.NewServer(t)
+
+	src.Enqueue(
+		groove.Task{ID: "export.a.1", Data: map[string]interface{}{"n": 1.0}, RetryThreshold: 2},
+		groove.Task{ID: "export.a.2", Data: "two"},
+		groove.Task{ID: "export.b.c.1"},
+		groove.Task{ID: "other.a.1"},
+	)
+
+	ctx := context.Background()
+
+	// export.a.1 is being processed, so it is only exported with -locked
+	_, err := src.Client.Dequeue(ctx, groove.DequeueTaskInput{DesiredTaskCount: 1, Prefix: "export.a", Timeout: 60000})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	dir := t.TempDir()
+
+	tests := []struct {
+		args []string
+		ids  []string
+	}{
+		{[]string{"export"}, []string{"export.a.2", "export.b.c.1"}},
+		{[]string{"-locked", "export"}, []string{"export.a.1", "export.a.2", "export.b.c.1"}},
+		{[]string{"-locked"}, []string{"export.a.1", "export.a.2", "export.b.c.1", "other.a.1"}},
+	}
+
+	for i, test := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("%d.ndjson", i))
+
+		err = commands["export"].run(ctx, src.Client, append([]string{"-o", path}, test.args...))
+		if err != nil {
+			t.Errorf("export %v: %s", test.args, err)
+			return
+		}
+
+		dst := groovetest.NewServer(t)
+
+		err = commands["import"].run(ctx, dst.Client, []string{"-chunk", "2", path})
+		if err != nil {
+			t.Errorf("import %v: %s", test.args, err)
+			return
+		}
+
+		var ids []string
+
+		for _, task := range dst.Queued("") {
+			ids = append(ids, task.ID)
+
+			if task.ID == "export.a.1" && (task.Data.(map[string]interface{})["n"] != 1.0 || task.RetryThreshold != 2) {
+				t.Errorf("expected export.a.1 to keep its data and retry threshold, got %+v", task)
+			}
+
+			if task.ID == "export.a.2" && task.Data != "two" {
+				t.Errorf("expected export.a.2 to keep its data, got %+v", task)
+			}
+		}
+
+		if strings.Join(ids, ",") != strings.Join(test.ids, ",") {
+			t.Errorf("export %v: expected %v to be imported, got %v", test.args, test.ids, ids)
+		}
+	}
+}
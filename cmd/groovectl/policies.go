@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func runPolicies(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("policies")
+	_ = fs.Parse(args)
+
+	if err := exactArgs(fs, 0); err != nil {
+		return err
+	}
+
+	res, err := c.Policies(ctx)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(res.Policies)
+}
+
+func runPolicy(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("policy")
+	_ = fs.Parse(args)
+
+	if err := exactArgs(fs, 1); err != nil {
+		return err
+	}
+
+	res, err := c.Policy(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	return printJSON(res)
+}
+
+func runSetPolicy(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("set-policy")
+	_ = fs.Parse(args)
+
+	if err := exactArgs(fs, 2); err != nil {
+		return err
+	}
+
+	var policy groove.Policy
+
+	err := json.Unmarshal([]byte(fs.Arg(1)), &policy)
+	if err != nil {
+		return fmt.Errorf("invalid policy: %w", err)
+	}
+
+	res, err := c.SetPolicy(ctx, fs.Arg(0), policy)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(res)
+}
+
+func runRemovePolicy(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("remove-policy")
+	_ = fs.Parse(args)
+
+	if err := exactArgs(fs, 1); err != nil {
+		return err
+	}
+
+	_, err := c.RemovePolicy(ctx, fs.Arg(0))
+	return err
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func runBrowse(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("browse")
+	_ = fs.Parse(args)
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected at most one prefix, got %d", fs.NArg())
+	}
+
+	input := groove.BrowseInput{Prefix: fs.Arg(0)}
+
+	var prefixes []groove.PrefixStats
+
+	for {
+		res, err := c.BrowsePrefixes(ctx, input)
+		if err != nil {
+			return err
+		}
+
+		prefixes = append(prefixes, res.Prefixes...)
+
+		if res.NextCursor == "" {
+			break
+		}
+
+		input.Cursor = res.NextCursor
+	}
+
+	printStats(prefixes)
+
+	return nil
+}
+
+func runStats(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("stats")
+	depth := fs.Int("depth", 1, "how many levels of descendants to include")
+	_ = fs.Parse(args)
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected at most one prefix, got %d", fs.NArg())
+	}
+
+	res, err := c.Stats(ctx, fs.Arg(0), *depth)
+	if err != nil {
+		return err
+	}
+
+	printStats(append([]groove.PrefixStats{res.Stats}, res.Children...))
+
+	return nil
+}
+
+// printStats writes prefix statistics to stdout as a table
+func printStats(stats []groove.PrefixStats) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PREFIX\tPENDING\tLOCKED\tTASK SETS\tOLDEST PENDING\tTHROUGHPUT")
+
+	for _, s := range stats {
+		prefix := s.Prefix
+		if prefix == "" {
+			prefix = "(root)"
+		}
+
+		oldest := "-"
+		if s.OldestPending != nil {
+			oldest = time.Since(*s.OldestPending).Round(time.Second).String()
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%.2f/s\n", prefix, s.Pending, s.Locked, s.InFlightTaskSets, oldest, s.Throughput)
+	}
+
+	_ = w.Flush()
+}
+
+func runPause(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("pause")
+	_ = fs.Parse(args)
+
+	if err := exactArgs(fs, 1); err != nil {
+		return err
+	}
+
+	_, err := c.Pause(ctx, fs.Arg(0))
+
+	return err
+}
+
+func runResume(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("resume")
+	_ = fs.Parse(args)
+
+	if err := exactArgs(fs, 1); err != nil {
+		return err
+	}
+
+	_, err := c.Resume(ctx, fs.Arg(0))
+
+	return err
+}
+
+func runPaused(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("paused")
+	_ = fs.Parse(args)
+
+	if err := exactArgs(fs, 0); err != nil {
+		return err
+	}
+
+	res, err := c.Paused(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range res.Prefixes {
+		fmt.Println(p)
+	}
+
+	return nil
+}
+
+func runQuota(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("quota")
+	_ = fs.Parse(args)
+
+	if err := exactArgs(fs, 0); err != nil {
+		return err
+	}
+
+	res, err := c.Quota(ctx)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(res.Quota)
+}
+
+func runConfig(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("config")
+	_ = fs.Parse(args)
+
+	if err := exactArgs(fs, 0); err != nil {
+		return err
+	}
+
+	res, err := c.Config(ctx)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(res.Config)
+}
+
+func runPurge(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("purge")
+	_ = fs.Parse(args)
+
+	if err := exactArgs(fs, 1); err != nil {
+		return err
+	}
+
+	res, err := c.Purge(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "purged %d task(s)\n", res.Purged)
+
+	return nil
+}
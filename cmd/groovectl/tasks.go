@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// maxChunk is the most tasks groove accepts in a single enqueue request
+const maxChunk = 1000
+
+func runEnqueue(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("enqueue")
+	wait := fs.Bool("wait", false, "wait for the tasks to finish and print their results")
+	chunk := fs.Int("chunk", maxChunk, "number of tasks sent per request")
+	_ = fs.Parse(args)
+
+	if err := exactArgs(fs, 1); err != nil {
+		return err
+	}
+
+	tasks, err := readTasks(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *wait {
+		// Waiting needs every task in the same request, so the results come back together
+		res, err := c.Enqueue(ctx, tasks, true)
+		if err != nil {
+			return err
+		}
+
+		return printJSON(res)
+	}
+
+	enqueued, err := enqueueChunks(ctx, c, tasks, *chunk)
+	fmt.Fprintf(os.Stderr, "enqueued %d task(s)\n", enqueued)
+
+	return err
+}
+
+// enqueueChunks enqueues tasks in requests of up to chunk tasks, returning how many were enqueued
+func enqueueChunks(ctx context.Context, c *groove.Client, tasks []groove.Task, chunk int) (int, error) {
+	if chunk < 1 || chunk > maxChunk {
+		return 0, fmt.Errorf("chunk must be between 1 and %d", maxChunk)
+	}
+
+	enqueued := 0
+
+	for start := 0; start < len(tasks); start += chunk {
+		end := start + chunk
+		if end > len(tasks) {
+			end = len(tasks)
+		}
+
+		_, err := c.Enqueue(ctx, tasks[start:end], false)
+		if err != nil {
+			return enqueued, err
+		}
+
+		enqueued += end - start
+	}
+
+	return enqueued, nil
+}
+
+// readTasks reads tasks from a file, or stdin when the name is "-". The file may hold a json array of tasks,
+// or a stream of task objects such as NDJSON
+func readTasks(name string) ([]groove.Task, error) {
+	var r io.Reader = os.Stdin
+
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+
+		defer f.Close()
+
+		r = f
+	}
+
+	br := bufio.NewReader(r)
+
+	first, err := peekNonSpace(br)
+	if err == io.EOF {
+		return nil, errors.New("no tasks to enqueue")
+	} else if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(br)
+
+	if first == '[' {
+		var tasks []groove.Task
+
+		err = dec.Decode(&tasks)
+		if err != nil {
+			return nil, err
+		}
+
+		return tasks, nil
+	}
+
+	var tasks []groove.Task
+
+	for line := 1; ; line++ {
+		var t groove.Task
+
+		err := dec.Decode(&t)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("task %d: %w", line, err)
+		}
+
+		tasks = append(tasks, t)
+	}
+
+	return tasks, nil
+}
+
+// peekNonSpace returns the first non whitespace byte without consuming it
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+func runDequeue(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("dequeue")
+	count := fs.Int("n", 1, "number of tasks to dequeue")
+	timeout := fs.Duration("timeout", 30*time.Second, "how long groove waits for an ack before the task set times out")
+	_ = fs.Parse(args)
+
+	if err := exactArgs(fs, 1); err != nil {
+		return err
+	}
+
+	res, err := c.Dequeue(ctx, groove.DequeueTaskInput{
+		DesiredTaskCount: *count,
+		Prefix:           fs.Arg(0),
+		Timeout:          int(timeout.Milliseconds()),
+	})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(res.TaskSet)
+}
+
+func runAck(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("ack")
+	taskID := fs.String("task", "", "only ack this task")
+	result := fs.String("result", "", "json result to store on the task")
+	_ = fs.Parse(args)
+
+	if err := exactArgs(fs, 1); err != nil {
+		return err
+	}
+
+	input := groove.AckInput{TaskSetID: fs.Arg(0)}
+
+	if *taskID != "" {
+		input.TaskID = taskID
+	}
+
+	if *result != "" {
+		err := json.Unmarshal([]byte(*result), &input.Result)
+		if err != nil {
+			return fmt.Errorf("invalid result: %w", err)
+		}
+	}
+
+	_, err := c.Ack(ctx, input)
+
+	return err
+}
+
+func runNack(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("nack")
+	taskID := fs.String("task", "", "only nack this task")
+	message := fs.String("error", "nacked by groovectl", "error to record on the failed tasks")
+	_ = fs.Parse(args)
+
+	if err := exactArgs(fs, 1); err != nil {
+		return err
+	}
+
+	input := groove.AckInput{TaskSetID: fs.Arg(0), Error: *message}
+
+	if *taskID != "" {
+		input.TaskID = taskID
+	}
+
+	_, err := c.Nack(ctx, input)
+
+	return err
+}
+
+func runCancel(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("cancel")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("expected at least one task id")
+	}
+
+	for _, id := range fs.Args() {
+		_, err := c.Cancel(ctx, id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+func runPeek(ctx context.Context, c *groove.Client, args []string) error {
+	fs := newFlagSet("peek")
+	count := fs.Int("n", 10, "number of tasks to show")
+	data := fs.Bool("data", false, "include task data")
+	_ = fs.Parse(args)
+
+	if err := exactArgs(fs, 1); err != nil {
+		return err
+	}
+
+	res, err := c.BrowseTasks(ctx, groove.BrowseInput{
+		Prefix: fs.Arg(0),
+		Limit:  *count,
+		Redact: !*data,
+	})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(res)
+}
@@ -0,0 +1,65 @@
+package groove
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+const (
+	// KeyIDHeader holds the id of the api key used to sign a request
+	KeyIDHeader = "X-Groove-Key-Id"
+
+	// TimestampHeader holds the unix time (in seconds) that a request was signed at
+	TimestampHeader = "X-Groove-Timestamp"
+
+	// NonceHeader holds a random value that is only used once, so that a signed request cannot be replayed
+	NonceHeader = "X-Groove-Nonce"
+
+	// RequestSignatureHeader holds the signature of a signed request
+	RequestSignatureHeader = "X-Groove-Request-Signature"
+)
+
+// NewNonce returns a random value to send in the NonceHeader
+func NewNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// Permission is an operation that an api key can be allowed to perform
+type Permission string
+
+const (
+	PermissionEnqueue Permission = "enqueue" // Enqueue tasks and follow the batches they are part of
+	PermissionDequeue Permission = "dequeue" // Dequeue tasks, and ack, nack and extend the task sets that were dequeued
+	PermissionRead    Permission = "read"    // Read stats, metrics, events and the contents of the queue
+	PermissionAdmin   Permission = "admin"   // Everything, including pausing, cancelling, purging and managing webhooks
+)
+
+// APIKey grants access to groove. A key can only act on tasks under its prefixes, a key without prefixes can act on every task.
+// Likewise a key can only be used in its namespaces, a key without namespaces can be used in every namespace.
+// With mutual tls a client can use a key by presenting a certificate whose common name, dns name, uri or email
+// address is the ClientCertificate of the key, in which case the key does not need a secret
+type APIKey struct {
+	ID                string       `json:"id"`
+	Secret            string       `json:"secret,omitempty"`
+	ClientCertificate string       `json:"client_certificate,omitempty"`
+	Prefixes          []string     `json:"prefixes,omitempty"`
+	Namespaces        []string     `json:"namespaces,omitempty"`
+	Permissions       []Permission `json:"permissions"`
+}
+
+// SignRequest computes the signature sent in the RequestSignatureHeader. The signature covers the method,
+// the path including the query string, the namespace the request acts on, the timestamp, the nonce and the body
+func SignRequest(secret string, method string, path string, namespace string, timestamp time.Time, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(method + "\n" + path + "\n" + namespace + "\n" + strconv.FormatInt(timestamp.Unix(), 10) + "\n" + nonce + "\n"))
+	_, _ = mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
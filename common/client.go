@@ -3,26 +3,98 @@ package groove
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Client struct {
 	baseURL string
 	client  *http.Client
+
+	apiKey    string // Sent as a bearer token
+	keyID     string // Used with keySecret to sign requests
+	keySecret string
+
+	namespace string // Sent in the NamespaceHeader
+
+	tlsConfig *tls.Config
+	timeout   time.Duration
+}
+
+// Option configures a Client
+type Option func(c *Client)
+
+// WithAPIKey authenticates requests by sending the secret of an api key as a bearer token
+func WithAPIKey(secret string) Option {
+	return func(c *Client) {
+		c.apiKey = secret
+	}
 }
 
-func New(baseURL string) *Client {
-	return &Client{
+// WithSignedRequests authenticates requests by signing them with the secret of an api key,
+// so that the secret itself is never sent
+func WithSignedRequests(keyID string, secret string) Option {
+	return func(c *Client) {
+		c.keyID = keyID
+		c.keySecret = secret
+	}
+}
+
+// WithNamespace makes every request act on a namespace instead of the default namespace
+func WithNamespace(name string) Option {
+	return func(c *Client) {
+		c.namespace = name
+	}
+}
+
+// WithTimeout limits how long a request to groove can take, including waiting on enqueued tasks. Defaults to 50s
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithHTTPClient replaces the http client used to talk to groove
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
 		baseURL: baseURL,
 		client: &http.Client{
 			Timeout: 50 * time.Second,
 		},
 	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.timeout > 0 {
+		client := *c.client
+		client.Timeout = c.timeout
+		c.client = &client
+	}
+
+	c.applyTLSConfig()
+
+	return c
 }
 
 type EnqueueResponse struct {
@@ -31,44 +103,108 @@ type EnqueueResponse struct {
 	Failed    *int   `json:"failed,omitempty"`
 	Status    string `json:"status"`
 	Tasks     []Task `json:"tasks,omitempty"`
+	BatchID   string `json:"batch_id,omitempty"`
 }
 
 func (c *Client) Enqueue(ctx context.Context, tasks []Task, wait bool) (*EnqueueResponse, error) {
-	jsb, err := json.Marshal(EnqueueTaskInput{Tasks: tasks})
+	waitTxt := ""
+
+	if wait {
+		waitTxt = "?wait=true"
+	}
+
+	var response EnqueueResponse
+
+	err := c.do(ctx, "POST", "/enqueue"+waitTxt, EnqueueTaskInput{Tasks: withTraceContext(ctx, tasks)}, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	waitTxt := ""
+	return &response, nil
+}
 
-	if wait {
-		waitTxt = "?wait=true"
+// EnqueueBatch enqueues tasks as part of a batch, the callback is optional
+func (c *Client) EnqueueBatch(ctx context.Context, batchID string, tasks []Task, callback *BatchCallback) (*EnqueueResponse, error) {
+	var response EnqueueResponse
+
+	err := c.do(ctx, "POST", "/enqueue", EnqueueTaskInput{Tasks: withTraceContext(ctx, tasks), BatchID: batchID, Callback: callback}, &response)
+	if err != nil {
+		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/enqueue%s", c.baseURL, waitTxt), bytes.NewReader(jsb))
+	return &response, nil
+}
+
+type BatchResponse struct {
+	Status string `json:"status"`
+	Batch  Batch  `json:"batch"`
+}
+
+// Batch fetches the progress of a batch
+func (c *Client) Batch(ctx context.Context, batchID string) (*BatchResponse, error) {
+	var response BatchResponse
+
+	err := c.do(ctx, "GET", "/batches/"+url.PathEscape(batchID), nil, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := c.client.Do(req)
+	return &response, nil
+}
+
+type DequeueResponse struct {
+	Status  string  `json:"status"`
+	TaskSet TaskSet `json:"task_set"`
+}
+
+func (c *Client) Dequeue(ctx context.Context, input DequeueTaskInput) (*DequeueResponse, error) {
+	var response DequeueResponse
+
+	err := c.do(ctx, "POST", "/dequeue", input, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	defer res.Body.Close()
+	return &response, nil
+}
 
-	body, err := ioutil.ReadAll(res.Body)
+type AckResponse struct {
+	Status   string `json:"status"`
+	Enqueued *int   `json:"enqueued,omitempty"`
+}
+
+// Ack acknowledges a task set, or a single task when input.TaskID is set.
+// Any tasks in input.Enqueue are enqueued atomically with the ack
+func (c *Client) Ack(ctx context.Context, input AckInput) (*AckResponse, error) {
+	var response AckResponse
+
+	input.Enqueue = withTraceContext(ctx, input.Enqueue)
+
+	err := c.do(ctx, "POST", "/ack", input, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	if res.StatusCode != 200 {
-		return nil, errors.New(string(body))
+	return &response, nil
+}
+
+func (c *Client) Nack(ctx context.Context, input AckInput) (*AckResponse, error) {
+	var response AckResponse
+
+	err := c.do(ctx, "POST", "/nack", input, &response)
+	if err != nil {
+		return nil, err
 	}
 
-	var response EnqueueResponse
+	return &response, nil
+}
+
+// Extend pushes back the timeout of an in flight task set to timeout from now.
+// The response holds the timeout that groove actually granted
+func (c *Client) Extend(ctx context.Context, taskSetID string, timeout time.Duration) (*ExtendResponse, error) {
+	var response ExtendResponse
 
-	err = json.Unmarshal(body, &response)
+	err := c.do(ctx, "POST", "/extend", ExtendInput{TaskSetID: taskSetID, Timeout: int(timeout.Milliseconds())}, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -76,41 +212,115 @@ func (c *Client) Enqueue(ctx context.Context, tasks []Task, wait bool) (*Enqueue
 	return &response, nil
 }
 
-type DequeueResponse struct {
-	Status  string  `json:"status"`
-	TaskSet TaskSet `json:"task_set"`
+type WebhookResponse struct {
+	Status  string              `json:"status"`
+	Webhook WebhookSubscription `json:"webhook"`
 }
 
-func (c *Client) Dequeue(ctx context.Context, input DequeueTaskInput) (*DequeueResponse, error) {
-	jsb, err := json.Marshal(input)
+// AddWebhook subscribes a url to task lifecycle events
+func (c *Client) AddWebhook(ctx context.Context, sub WebhookSubscription) (*WebhookResponse, error) {
+	var response WebhookResponse
+
+	err := c.do(ctx, "POST", "/webhooks", sub, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/dequeue", c.baseURL), bytes.NewReader(jsb))
+	return &response, nil
+}
+
+type WebhooksResponse struct {
+	Status   string                `json:"status"`
+	Webhooks []WebhookSubscription `json:"webhooks"`
+}
+
+// Webhooks lists the webhook subscriptions, secrets are not returned
+func (c *Client) Webhooks(ctx context.Context) (*WebhooksResponse, error) {
+	var response WebhooksResponse
+
+	err := c.do(ctx, "GET", "/webhooks", nil, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := c.client.Do(req)
+	return &response, nil
+}
+
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+type ExtendResponse struct {
+	Status  string `json:"status"`
+	Timeout int    `json:"timeout"` // Number of milliseconds from now that the task set will time out
+}
+
+// RemoveWebhook deletes a webhook subscription
+func (c *Client) RemoveWebhook(ctx context.Context, id string) (*StatusResponse, error) {
+	var response StatusResponse
+
+	err := c.do(ctx, "DELETE", "/webhooks/"+url.PathEscape(id), nil, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	defer res.Body.Close()
+	return &response, nil
+}
 
-	body, err := ioutil.ReadAll(res.Body)
+type StatsResponse struct {
+	Status   string        `json:"status"`
+	Stats    PrefixStats   `json:"stats"`
+	Children []PrefixStats `json:"children"`
+}
+
+// Stats fetches statistics for a prefix, and for each of its descendants depth levels below it
+func (c *Client) Stats(ctx context.Context, prefix string, depth int) (*StatsResponse, error) {
+	var response StatsResponse
+
+	q := url.Values{}
+	q.Set("prefix", prefix)
+	q.Set("depth", strconv.Itoa(depth))
+
+	err := c.do(ctx, "GET", "/stats?"+q.Encode(), nil, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	if res.StatusCode != 200 {
-		return nil, errors.New(string(body))
+	return &response, nil
+}
+
+// BrowseInput selects a page of a browse listing
+type BrowseInput struct {
+	Prefix string
+	Cursor string // The NextCursor of the previous page, empty for the first page
+	Limit  int    // Zero uses the server default
+	Redact bool   // Leave out task data, only used when browsing tasks
+}
+
+func (b BrowseInput) query() string {
+	q := url.Values{}
+	q.Set("prefix", b.Prefix)
+
+	if b.Cursor != "" {
+		q.Set("cursor", b.Cursor)
 	}
 
-	var response DequeueResponse
+	if b.Limit > 0 {
+		q.Set("limit", strconv.Itoa(b.Limit))
+	}
+
+	if b.Redact {
+		q.Set("redact", "true")
+	}
 
-	err = json.Unmarshal(body, &response)
+	return q.Encode()
+}
+
+// BrowsePrefixes lists a page of the prefixes directly below a prefix
+func (c *Client) BrowsePrefixes(ctx context.Context, input BrowseInput) (*BrowsePrefixesResponse, error) {
+	var response BrowsePrefixesResponse
+
+	err := c.do(ctx, "GET", "/browse/prefixes?"+input.query(), nil, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -118,40 +328,168 @@ func (c *Client) Dequeue(ctx context.Context, input DequeueTaskInput) (*DequeueR
 	return &response, nil
 }
 
-type AckResponse struct {
+// BrowseTasks lists a page of the tasks waiting in a prefix
+func (c *Client) BrowseTasks(ctx context.Context, input BrowseInput) (*BrowseTasksResponse, error) {
+	var response BrowseTasksResponse
+
+	err := c.do(ctx, "GET", "/browse/tasks?"+input.query(), nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+type PausedResponse struct {
+	Status   string   `json:"status"`
+	Prefixes []string `json:"prefixes"`
+}
+
+// Pause stops tasks under a prefix from being dequeued until it is resumed, an empty prefix pauses everything
+func (c *Client) Pause(ctx context.Context, prefix string) (*StatusResponse, error) {
+	var response StatusResponse
+
+	err := c.do(ctx, "POST", "/pause", PrefixInput{Prefix: prefix}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Resume allows tasks under a paused prefix to be dequeued again
+func (c *Client) Resume(ctx context.Context, prefix string) (*StatusResponse, error) {
+	var response StatusResponse
+
+	err := c.do(ctx, "POST", "/resume", PrefixInput{Prefix: prefix}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Paused lists the paused prefixes
+func (c *Client) Paused(ctx context.Context) (*PausedResponse, error) {
+	var response PausedResponse
+
+	err := c.do(ctx, "GET", "/paused", nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Cancel removes a pending task from the queue, tasks that are being processed cannot be cancelled
+func (c *Client) Cancel(ctx context.Context, taskID string) (*StatusResponse, error) {
+	var response StatusResponse
+
+	err := c.do(ctx, "POST", "/cancel", CancelInput{TaskID: taskID}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+type PurgeResponse struct {
 	Status string `json:"status"`
+	Purged int    `json:"purged"`
 }
 
-func (c *Client) Ack(ctx context.Context, input AckInput) (*AckResponse, error) {
-	jsb, err := json.Marshal(input)
+// Purge removes every pending task under a prefix
+func (c *Client) Purge(ctx context.Context, prefix string) (*PurgeResponse, error) {
+	var response PurgeResponse
+
+	err := c.do(ctx, "POST", "/purge", PrefixInput{Prefix: prefix}, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/ack", c.baseURL), bytes.NewReader(jsb))
+	return &response, nil
+}
+
+type QuotaResponse struct {
+	Status string     `json:"status"`
+	Quota  QuotaUsage `json:"quota"`
+}
+
+// Quota reports the quota of the namespace and how much of it is used
+func (c *Client) Quota(ctx context.Context) (*QuotaResponse, error) {
+	var response QuotaResponse
+
+	err := c.do(ctx, "GET", "/quota", nil, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := c.client.Do(req)
+	return &response, nil
+}
+
+type HealthResponse struct {
+	Status     string   `json:"status"`
+	Namespaces []Health `json:"namespaces"`
+}
+
+// Health checks that every namespace of groove is alive, an error is returned if any of them is not
+func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
+	var response HealthResponse
+
+	err := c.do(ctx, "GET", "/healthz", nil, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	defer res.Body.Close()
+	return &response, nil
+}
 
-	body, err := ioutil.ReadAll(res.Body)
+// Ready checks that every namespace of groove can accept writes, an error is returned if any of them cannot
+func (c *Client) Ready(ctx context.Context) (*HealthResponse, error) {
+	var response HealthResponse
+
+	err := c.do(ctx, "GET", "/readyz", nil, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	if res.StatusCode != 200 {
-		return nil, errors.New(string(body))
+	return &response, nil
+}
+
+type ConfigResponse struct {
+	Status string          `json:"status"`
+	Config json.RawMessage `json:"config"`
+}
+
+// Config returns the settings that groove is using, as json
+func (c *Client) Config(ctx context.Context) (*ConfigResponse, error) {
+	var response ConfigResponse
+
+	err := c.do(ctx, "GET", "/config", nil, &response)
+	if err != nil {
+		return nil, err
 	}
 
-	var response AckResponse
+	return &response, nil
+}
 
-	err = json.Unmarshal(body, &response)
+type PolicyResponse struct {
+	Status    string  `json:"status"`
+	Prefix    string  `json:"prefix"`
+	Policy    *Policy `json:"policy,omitempty"` // The policy set on the prefix itself, if there is one
+	Effective Policy  `json:"effective"`        // The inherited settings that apply to tasks under the prefix
+}
+
+type PoliciesResponse struct {
+	Status   string            `json:"status"`
+	Policies map[string]Policy `json:"policies"`
+}
+
+// Policies lists every policy by prefix
+func (c *Client) Policies(ctx context.Context) (*PoliciesResponse, error) {
+	var response PoliciesResponse
+
+	err := c.do(ctx, "GET", "/policies", nil, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -159,39 +497,202 @@ func (c *Client) Ack(ctx context.Context, input AckInput) (*AckResponse, error)
 	return &response, nil
 }
 
-func (c *Client) Nack(ctx context.Context, input AckInput) (*AckResponse, error) {
-	jsb, err := json.Marshal(input)
+// Policy returns the policy set on a prefix, and the settings that apply to tasks under it
+func (c *Client) Policy(ctx context.Context, prefix string) (*PolicyResponse, error) {
+	var response PolicyResponse
+
+	err := c.do(ctx, "GET", "/policies/"+url.PathEscape(prefix), nil, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/nack", c.baseURL), bytes.NewReader(jsb))
+	return &response, nil
+}
+
+// SetPolicy sets the policy of a prefix, replacing any policy it had
+func (c *Client) SetPolicy(ctx context.Context, prefix string, policy Policy) (*PolicyResponse, error) {
+	var response PolicyResponse
+
+	err := c.do(ctx, "PUT", "/policies/"+url.PathEscape(prefix), policy, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := c.client.Do(req)
+	return &response, nil
+}
+
+// RemovePolicy removes the policy of a prefix, tasks under it go back to inheriting their settings
+func (c *Client) RemovePolicy(ctx context.Context, prefix string) (*StatusResponse, error) {
+	var response StatusResponse
+
+	err := c.do(ctx, "DELETE", "/policies/"+url.PathEscape(prefix), nil, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	defer res.Body.Close()
+	return &response, nil
+}
 
-	body, err := ioutil.ReadAll(res.Body)
+type TaskSetsResponse struct {
+	Status   string       `json:"status"`
+	TaskSets []TaskSetLog `json:"task_sets"`
+}
+
+// TaskSets lists up to limit in flight task sets, soonest timeout first. Zero uses the server default limit
+func (c *Client) TaskSets(ctx context.Context, limit int) (*TaskSetsResponse, error) {
+	var response TaskSetsResponse
+
+	path := "/tasksets"
+	if limit > 0 {
+		path += "?limit=" + strconv.Itoa(limit)
+	}
+
+	err := c.do(ctx, "GET", path, nil, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	if res.StatusCode != 200 {
-		return nil, errors.New(string(body))
+	return &response, nil
+}
+
+type DeadLettersResponse struct {
+	Status      string       `json:"status"`
+	DeadLetters []DeadLetter `json:"dead_letters"`
+}
+
+// DeadLetters lists up to limit dead letters under a prefix, oldest first. Zero uses the server default limit
+func (c *Client) DeadLetters(ctx context.Context, prefix string, limit int) (*DeadLettersResponse, error) {
+	var response DeadLettersResponse
+
+	q := url.Values{}
+	q.Set("prefix", prefix)
+
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
 	}
 
-	var response AckResponse
+	err := c.do(ctx, "GET", "/dlq?"+q.Encode(), nil, &response)
+	if err != nil {
+		return nil, err
+	}
 
-	err = json.Unmarshal(body, &response)
+	return &response, nil
+}
+
+type RetryDeadLettersResponse struct {
+	Status  string `json:"status"`
+	Retried int    `json:"retried"`
+}
+
+// RetryDeadLetters enqueues dead lettered tasks again with a fresh retry count
+func (c *Client) RetryDeadLetters(ctx context.Context, input DeadLettersInput) (*RetryDeadLettersResponse, error) {
+	var response RetryDeadLettersResponse
+
+	err := c.do(ctx, "POST", "/dlq/retry", input, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// PurgeDeadLetters deletes dead letters
+func (c *Client) PurgeDeadLetters(ctx context.Context, input DeadLettersInput) (*PurgeResponse, error) {
+	var response PurgeResponse
+
+	err := c.do(ctx, "POST", "/dlq/purge", input, &response)
 	if err != nil {
 		return nil, err
 	}
 
 	return &response, nil
 }
+
+// withTraceContext returns a copy of tasks with the trace context of ctx injected
+func withTraceContext(ctx context.Context, tasks []Task) []Task {
+	if len(tasks) == 0 {
+		return tasks
+	}
+
+	traced := make([]Task, len(tasks))
+
+	for i, t := range tasks {
+		InjectTraceContext(ctx, &t)
+		traced[i] = t
+	}
+
+	return traced
+}
+
+// do sends a request to groove, encoding input as the json body (if not nil) and decoding the response into output
+func (c *Client) do(ctx context.Context, method string, path string, input interface{}, output interface{}) (err error) {
+	ctx, span := tracer.Start(ctx, "groove "+method+" "+strings.SplitN(path, "?", 2)[0], trace.WithSpanKind(trace.SpanKindClient))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		span.End()
+	}()
+
+	var reqBody io.Reader
+	var jsb []byte
+
+	if input != nil {
+		jsb, err = json.Marshal(input)
+		if err != nil {
+			return err
+		}
+
+		reqBody = bytes.NewReader(jsb)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s%s", c.baseURL, path), reqBody)
+	if err != nil {
+		return err
+	}
+
+	Propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	if c.namespace != "" {
+		req.Header.Set(NamespaceHeader, c.namespace)
+	}
+
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	if c.keyID != "" {
+		now := time.Now()
+		nonce := NewNonce()
+
+		namespace := c.namespace
+		if namespace == "" {
+			namespace = DefaultNamespace
+		}
+
+		req.Header.Set(KeyIDHeader, c.keyID)
+		req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+		req.Header.Set(NonceHeader, nonce)
+		req.Header.Set(RequestSignatureHeader, SignRequest(c.keySecret, method, req.URL.RequestURI(), namespace, now, nonce, jsb))
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != 200 {
+		return errors.New(string(body))
+	}
+
+	return json.Unmarshal(body, output)
+}
@@ -0,0 +1,21 @@
+package groove
+
+import "time"
+
+// Health describes one namespace of a groove server
+type Health struct {
+	Namespace string    `json:"namespace"`
+	Alive     bool      `json:"alive"`     // The timeout loop is running
+	LastTick  time.Time `json:"last_tick"` // When the timeout loop last ran
+	Draining  bool      `json:"draining"`  // Dequeues have been stopped for a shutdown
+	Writable  bool      `json:"writable"`  // Tasks can currently be enqueued and dequeued
+
+	Storage *StorageHealth `json:"storage,omitempty"` // Only set when the namespace has storage
+}
+
+// StorageHealth describes the last attempt to save a snapshot
+type StorageHealth struct {
+	Healthy    bool      `json:"healthy"`
+	LastSaveAt time.Time `json:"last_save_at,omitempty"` // The last successful save
+	Error      string    `json:"error,omitempty"`        // Why the last save failed
+}
@@ -0,0 +1,163 @@
+package groove
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrNoRoute is returned (wrapped) by Mux.HandleTask when no pattern matches a task id
+var ErrNoRoute = errors.New("no handler matches task")
+
+// Mux routes tasks to handlers by matching task ids against dotted patterns such as "memory.*.index".
+// In a pattern * matches exactly one part of an id, and a trailing ** matches one or more parts.
+// When several patterns match, the most specific wins: parts are compared left to right, with a
+// literal beating * and * beating **
+type Mux struct {
+	mx     sync.RWMutex
+	routes []route
+}
+
+type route struct {
+	pattern string
+	parts   []string
+	handler Handler
+}
+
+func NewMux() *Mux {
+	return &Mux{}
+}
+
+// Handle registers a handler for a pattern. It panics if the pattern is invalid or already registered
+func (m *Mux) Handle(pattern string, handler Handler) {
+	parts := strings.Split(pattern, ".")
+
+	for i, p := range parts {
+		if p == "" {
+			panic(fmt.Sprintf("groove: invalid pattern %q, parts cannot be empty", pattern))
+		}
+
+		if p == "**" && i != len(parts)-1 {
+			panic(fmt.Sprintf("groove: invalid pattern %q, ** can only be the last part", pattern))
+		}
+
+		if p != "*" && p != "**" && strings.Contains(p, "*") {
+			panic(fmt.Sprintf("groove: invalid pattern %q, wildcards must be a whole part", pattern))
+		}
+	}
+
+	if handler == nil {
+		panic("groove: nil handler for pattern " + pattern)
+	}
+
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	for _, r := range m.routes {
+		if r.pattern == pattern {
+			panic(fmt.Sprintf("groove: pattern %q is already registered", pattern))
+		}
+	}
+
+	m.routes = append(m.routes, route{pattern: pattern, parts: parts, handler: handler})
+}
+
+// HandleTask passes a task to the handler with the most specific matching pattern, it can be used as
+// the Handler of a Worker. Tasks that match no pattern fail with an error wrapping ErrNoRoute
+func (m *Mux) HandleTask(ctx context.Context, task Task) (interface{}, error) {
+	m.mx.RLock()
+
+	idParts := strings.Split(task.ID, ".")
+
+	var best *route
+	var patterns []string
+
+	for i := range m.routes {
+		r := &m.routes[i]
+		patterns = append(patterns, r.pattern)
+
+		if r.matches(idParts) && (best == nil || r.moreSpecific(best)) {
+			best = r
+		}
+	}
+
+	m.mx.RUnlock()
+
+	if best == nil {
+		return nil, fmt.Errorf("%w %s, registered patterns are [%s]", ErrNoRoute, task.ID, strings.Join(patterns, ", "))
+	}
+
+	return best.handler(ctx, task)
+}
+
+func (r *route) matches(idParts []string) bool {
+	for i, p := range r.parts {
+		if p == "**" {
+			return len(idParts) > i
+		}
+
+		if i >= len(idParts) || (p != "*" && p != idParts[i]) {
+			return false
+		}
+	}
+
+	return len(idParts) == len(r.parts)
+}
+
+// moreSpecific checks if r should be picked over other when both match the same id
+func (r *route) moreSpecific(other *route) bool {
+	for i := 0; i < len(r.parts) && i < len(other.parts); i++ {
+		a, b := partRank(r.parts[i]), partRank(other.parts[i])
+		if a != b {
+			return a < b
+		}
+	}
+
+	// The patterns only differ in length, which can only happen when the shorter one ends in **
+	return len(r.parts) > len(other.parts)
+}
+
+// partRank orders pattern parts from most to least specific
+func partRank(p string) int {
+	switch p {
+	case "*":
+		return 1
+	case "**":
+		return 2
+	default:
+		return 0
+	}
+}
+
+// Typed adapts a handler that takes typed task data into a Handler. Task.Data is decoded into a T,
+// tasks whose data cannot be decoded fail without the handler being called
+func Typed[T any](handler func(ctx context.Context, task Task, data T) (interface{}, error)) Handler {
+	return func(ctx context.Context, task Task) (interface{}, error) {
+		var data T
+
+		err := DecodeData(task, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, task, data)
+	}
+}
+
+// DecodeData decodes the data of a task into v, which must be a pointer
+func DecodeData(task Task, v interface{}) error {
+	b, err := json.Marshal(task.Data)
+	if err != nil {
+		return fmt.Errorf("could not decode data of task %s: %w", task.ID, err)
+	}
+
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return fmt.Errorf("could not decode data of task %s into %T: %w", task.ID, v, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package groove
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMux(t *testing.T) {
+	mux := NewMux()
+
+	route := func(name string) Handler {
+		return func(ctx context.Context, task Task) (interface{}, error) {
+			return name, nil
+		}
+	}
+
+	mux.Handle("memory.*.index", route("index"))
+	mux.Handle("memory.*.compact", route("compact"))
+	mux.Handle("memory.users.index", route("users index"))
+	mux.Handle("memory.**", route("memory"))
+	mux.Handle("memory.*.*", route("any"))
+
+	cases := map[string]string{
+		"memory.orders.index":   "index",
+		"memory.orders.compact": "compact",
+		"memory.users.index":    "users index",
+		"memory.orders.other":   "any",
+		"memory.orders":         "memory",
+		"memory.a.b.c":          "memory",
+	}
+
+	for id, expected := range cases {
+		res, err := mux.HandleTask(context.Background(), Task{ID: id})
+		if err != nil {
+			t.Errorf("%s: %s", id, err)
+			continue
+		}
+
+		if res != expected {
+			t.Errorf("expected %s to be routed to %q, got %q", id, expected, res)
+		}
+	}
+
+	_, err := mux.HandleTask(context.Background(), Task{ID: "other.task"})
+	if !errors.Is(err, ErrNoRoute) {
+		t.Errorf("expected an unroutable task to fail with ErrNoRoute, got %v", err)
+	}
+}
+
+func TestMux_InvalidPattern(t *testing.T) {
+	for _, pattern := range []string{"memory..index", "memory.**.index", "memory.ind*"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected pattern %q to be rejected", pattern)
+				}
+			}()
+
+			NewMux().Handle(pattern, func(ctx context.Context, task Task) (interface{}, error) {
+				return nil, nil
+			})
+		}()
+	}
+}
+
+func TestTyped(t *testing.T) {
+	type indexJob struct {
+		Table string `json:"table"`
+		Limit int    `json:"limit"`
+	}
+
+	h := Typed(func(ctx context.Context, task Task, job indexJob) (interface{}, error) {
+		return job, nil
+	})
+
+	// Data arrives from groove as decoded json
+	res, err := h(context.Background(), Task{ID: "memory.a.index", Data: map[string]interface{}{"table": "users", "limit": 10.0}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if job := res.(indexJob); job.Table != "users" || job.Limit != 10 {
+		t.Errorf("expected data to be decoded, got %+v", job)
+	}
+
+	_, err = h(context.Background(), Task{ID: "memory.a.index", Data: "not an object"})
+	if err == nil {
+		t.Error("expected data of the wrong shape to fail")
+	}
+}
@@ -0,0 +1,26 @@
+package groove
+
+const (
+	// NamespaceHeader selects the namespace that a request acts on
+	NamespaceHeader = "X-Groove-Namespace"
+
+	// DefaultNamespace is used by requests that do not select a namespace
+	DefaultNamespace = "default"
+)
+
+// Quota caps how much a namespace holds, a limit of zero is unlimited
+type Quota struct {
+	MaxPendingTasks     int   `json:"max_pending_tasks,omitempty"`       // Tasks stored, whether waiting or being processed
+	MaxPayloadBytes     int64 `json:"max_payload_bytes,omitempty"`       // Total size of the json encoded data of stored tasks
+	MaxInFlightTaskSets int   `json:"max_in_flight_task_sets,omitempty"` // Task sets dequeued but not yet finished
+}
+
+// QuotaUsage is how much of its quota a namespace is using.
+// Payload bytes are only counted when the quota limits them
+type QuotaUsage struct {
+	Namespace        string `json:"namespace"`
+	Quota            Quota  `json:"quota"`
+	PendingTasks     int    `json:"pending_tasks"`
+	PayloadBytes     int64  `json:"payload_bytes"`
+	InFlightTaskSets int    `json:"in_flight_task_sets"`
+}
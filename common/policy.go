@@ -0,0 +1,52 @@
+package groove
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is a time.Duration written in json as a string, such as "30s"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("durations must be strings such as \"30s\"")
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+// Policy holds the server side settings for every task under a prefix.
+// RetryThreshold, DequeueTimeout, MaxLease, MaxPayloadBytes and DeadLetter are inherited, a setting left as nil
+// is taken from the policy of the closest parent prefix that sets it, or from the server defaults. Setting one to
+// zero overrides the parent, zero MaxLease and MaxPayloadBytes mean no limit beyond the server's own limits.
+// Concurrency and RateLimit are not inherited, they limit the prefix as a whole, including every prefix below it
+type Policy struct {
+	RetryThreshold  *int      `json:"retry_threshold,omitempty"`   // Used by tasks that do not set their own retry threshold
+	DequeueTimeout  *Duration `json:"dequeue_timeout,omitempty"`   // Used by dequeues that do not set a timeout
+	MaxLease        *Duration `json:"max_lease,omitempty"`         // The longest a task set can be locked for by a dequeue or extend
+	MaxPayloadBytes *int64    `json:"max_payload_bytes,omitempty"` // The largest json encoded data of a single task
+	DeadLetter      *bool     `json:"dead_letter,omitempty"`       // False drops failed tasks instead of keeping them as dead letters
+
+	Concurrency int     `json:"concurrency,omitempty"` // The most tasks that can be locked at once
+	RateLimit   float64 `json:"rate_limit,omitempty"`  // The most tasks dequeued per second
+}
+
+// Ptr returns a pointer to v, for setting the inherited settings of a Policy
+func Ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,72 @@
+package groove
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// WithTLSConfig sets the tls config used to connect to groove, for example to trust a private CA
+// or to present a client certificate for mutual tls. It is ignored if WithHTTPClient gives a client
+// whose transport is not an *http.Transport
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Client) {
+		c.tlsConfig = config
+	}
+}
+
+// LoadTLSConfig builds a tls config for WithTLSConfig. caFile replaces the system CAs with the CAs in the file,
+// and certFile with keyFile is presented as the client certificate. Any of them can be empty
+func LoadTLSConfig(caFile string, certFile string, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		b, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+
+		if !config.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// applyTLSConfig makes the http client use the tls config, leaving the rest of its transport as it was
+func (c *Client) applyTLSConfig() {
+	if c.tlsConfig == nil {
+		return
+	}
+
+	transport, ok := c.client.Transport.(*http.Transport)
+	if c.client.Transport == nil {
+		transport, ok = http.DefaultTransport.(*http.Transport)
+	}
+
+	if !ok {
+		return
+	}
+
+	// The config is copied as the transport adds the protocols it offers to it
+	transport = transport.Clone()
+	transport.TLSClientConfig = c.tlsConfig.Clone()
+
+	client := *c.client
+	client.Transport = transport
+	c.client = &client
+}
@@ -0,0 +1,42 @@
+package groove
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// TracerName is the instrumentation name used for spans created by groove
+const TracerName = "github.com/datomar-labs-inc/groove"
+
+// Propagator carries W3C trace context and baggage across the groove boundary, both in
+// http headers and in Task.TraceContext. It is used regardless of the global otel propagator
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+var tracer = otel.Tracer(TracerName)
+
+// InjectTraceContext stores the trace context of ctx on the task, so that whoever processes
+// the task can continue the trace. A task that already carries a trace context is left alone
+func InjectTraceContext(ctx context.Context, task *Task) {
+	if len(task.TraceContext) > 0 {
+		return
+	}
+
+	carrier := propagation.MapCarrier{}
+	Propagator.Inject(ctx, carrier)
+
+	if len(carrier) > 0 {
+		task.TraceContext = carrier
+	}
+}
+
+// Context returns a copy of ctx carrying the trace context of the task, spans started from
+// the returned context will be part of the trace that enqueued the task
+func (t Task) Context(ctx context.Context) context.Context {
+	if len(t.TraceContext) == 0 {
+		return ctx
+	}
+
+	return Propagator.Extract(ctx, propagation.MapCarrier(t.TraceContext))
+}
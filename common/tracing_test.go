@@ -0,0 +1,41 @@
+package groove
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTask_TraceContext(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "producer")
+	defer span.End()
+
+	task := Task{ID: "traced.task"}
+
+	InjectTraceContext(ctx, &task)
+
+	if task.TraceContext["traceparent"] == "" {
+		t.Error("expected a traceparent to be injected")
+		return
+	}
+
+	sc := trace.SpanContextFromContext(task.Context(context.Background()))
+
+	if sc.TraceID() != span.SpanContext().TraceID() || sc.SpanID() != span.SpanContext().SpanID() {
+		t.Error("expected the task context to continue the producer trace")
+	}
+
+	// An existing trace context must not be replaced
+	other, otherSpan := tp.Tracer("test").Start(context.Background(), "other")
+	defer otherSpan.End()
+
+	InjectTraceContext(other, &task)
+
+	if trace.SpanContextFromContext(task.Context(context.Background())).TraceID() != span.SpanContext().TraceID() {
+		t.Error("expected the original trace context to be kept")
+	}
+}
@@ -14,6 +14,13 @@ type Task struct {
 	Errors         []interface{} `json:"errors,omitempty"`
 	Result         interface{}   `json:"result,omitempty"`
 	RetryCount     int           `json:"-"`
+	Seq            uint64        `json:"-"`                  // Set by groove when the task is enqueued, increases with every task
+	PayloadSize    int64         `json:"-"`                  // Set by groove when it needs to track the size of the task data
+	BatchID        string        `json:"batch_id,omitempty"` // Set by groove when the task was enqueued as part of a batch
+	EnqueuedAt     time.Time     `json:"enqueued_at"`        // Set by groove when the task is enqueued
+
+	// TraceContext holds the W3C trace context (traceparent, tracestate and baggage) of whoever enqueued the task
+	TraceContext map[string]string `json:"trace_context,omitempty"`
 }
 
 // TaskSetLog keeps track of a task set, noting which tasks are included in it
@@ -21,16 +28,20 @@ type TaskSetLog struct {
 	ID        string    `json:"id"`
 	TaskIDs   []string  `json:"task_ids"`
 	TimeoutAt time.Time `json:"timeout_at"`
+	Owner     string    `json:"owner,omitempty"` // The id of the api key that dequeued the task set
 }
 
 // TaskSet is a group of tasks that should be processed at once
 type TaskSet struct {
-	ID    string `json:"id"`
-	Tasks []Task `json:"tasks"`
+	ID      string `json:"id"`
+	Tasks   []Task `json:"tasks"`
+	Timeout int    `json:"timeout"` // Number of milliseconds groove leased the task set for, it times out unless extended within that time
 }
 
 type EnqueueTaskInput struct {
-	Tasks []Task `json:"tasks"`
+	Tasks    []Task         `json:"tasks"`
+	BatchID  string         `json:"batch_id,omitempty"` // Optionally track the tasks as part of a batch
+	Callback *BatchCallback `json:"callback,omitempty"` // Only used when a BatchID is provided
 }
 
 type DequeueTaskInput struct {
@@ -44,4 +55,124 @@ type AckInput struct {
 	TaskID    *string     `json:"task_id,omitempty"`
 	Error     interface{} `json:"error,omitempty"`
 	Result    interface{} `json:"result,omitempty"`
+	Enqueue   []Task      `json:"enqueue,omitempty"` // Tasks to enqueue in the same operation as an ack, ignored for nacks
+}
+
+// ExtendInput pushes back the timeout of an in flight task set
+type ExtendInput struct {
+	TaskSetID string `json:"task_set_id"`
+	Timeout   int    `json:"timeout"` // Number of milliseconds from now that the task set will time out
+}
+
+// Batch tracks the progress of a group of tasks that were enqueued under the same batch id
+type Batch struct {
+	ID          string         `json:"id"`
+	Pending     int            `json:"pending"`
+	Succeeded   int            `json:"succeeded"`
+	Failed      int            `json:"failed"`
+	Complete    bool           `json:"complete"`
+	CreatedAt   time.Time      `json:"created_at"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+	Callback    *BatchCallback `json:"callback,omitempty"`
+}
+
+// BatchCallback describes a task that is enqueued once every task in a batch has finished.
+// The callback task will have the id <prefix>.<batch id> and carry a BatchResult as its data
+type BatchCallback struct {
+	Prefix         string      `json:"prefix"`
+	Data           interface{} `json:"data,omitempty"`
+	RetryThreshold int         `json:"retry_threshold"`
+}
+
+// BatchResult is the aggregated outcome of a batch, delivered as the data of a batch callback task
+type BatchResult struct {
+	BatchID   string                   `json:"batch_id"`
+	Succeeded int                      `json:"succeeded"`
+	Failed    int                      `json:"failed"`
+	Results   map[string]interface{}   `json:"results,omitempty"` // Results of succeeded tasks, keyed by task id
+	Errors    map[string][]interface{} `json:"errors,omitempty"`  // Errors of failed tasks, keyed by task id
+	Data      interface{}              `json:"data,omitempty"`    // The data provided in the BatchCallback
+}
+
+// EventType is the kind of task lifecycle event that occurred
+type EventType string
+
+const (
+	EventEnqueued     EventType = "enqueued"
+	EventDequeued     EventType = "dequeued"
+	EventAcked        EventType = "acked"
+	EventNacked       EventType = "nacked"
+	EventDeadLettered EventType = "dead_lettered" // The task has failed more times than its retry threshold allows
+	EventTimedOut     EventType = "timed_out"     // The task set containing the task was not acked before its timeout
+)
+
+// EventTypes lists every event type groove can emit
+var EventTypes = []EventType{EventEnqueued, EventDequeued, EventAcked, EventNacked, EventDeadLettered, EventTimedOut}
+
+// Event describes something that happened to a task
+type Event struct {
+	Seq       uint64    `json:"seq"` // Increases by one for every event, used to resume an event stream
+	Type      EventType `json:"type"`
+	TaskID    string    `json:"task_id"`
+	TaskSetID string    `json:"task_set_id,omitempty"`
+	Time      time.Time `json:"time"`
+	Task      Task      `json:"task"` // A snapshot of the task at the time of the event
+}
+
+// WebhookSubscription delivers events for tasks under a prefix to a url
+type WebhookSubscription struct {
+	ID     string      `json:"id"`
+	URL    string      `json:"url"`
+	Prefix string      `json:"prefix,omitempty"` // Only tasks with ids under this prefix will be delivered, empty means all tasks
+	Events []EventType `json:"events,omitempty"` // The event types to deliver, empty means all events
+	Secret string      `json:"secret,omitempty"` // Used to sign deliveries, see SignWebhook
+}
+
+// PrefixStats describes the tasks under a prefix
+type PrefixStats struct {
+	Prefix           string     `json:"prefix"`
+	Pending          int        `json:"pending"`             // Tasks waiting to be dequeued
+	Locked           int        `json:"locked"`              // Containers with a task currently being processed
+	InFlightTaskSets int        `json:"in_flight_task_sets"` // Task sets holding at least one task under the prefix
+	OldestPending    *time.Time `json:"oldest_pending,omitempty"`
+	Throughput       float64    `json:"throughput"` // Tasks finished per second, averaged over roughly the last minute
+}
+
+// BrowsePrefixesResponse is a page of the prefixes directly below a prefix
+type BrowsePrefixesResponse struct {
+	Status     string        `json:"status"`
+	Prefixes   []PrefixStats `json:"prefixes"`
+	NextCursor string        `json:"next_cursor,omitempty"` // Pass as the cursor to get the next page, empty on the last page
+}
+
+// BrowseTasksResponse is a page of the tasks waiting under a prefix
+type BrowseTasksResponse struct {
+	Status     string `json:"status"`
+	LockedTask *Task  `json:"locked_task,omitempty"` // The task currently being processed, only included on the first page
+	LockedBy   string `json:"locked_by,omitempty"`   // The id of the task set the locked task belongs to
+	Tasks      []Task `json:"tasks"`                 // Pending tasks in the order they will be dequeued
+	NextCursor string `json:"next_cursor,omitempty"` // Pass as the cursor to get the next page, empty on the last page
+}
+
+// DeadLetter is a task that failed more times than its retry threshold allows
+type DeadLetter struct {
+	Task      Task      `json:"task"`
+	TaskSetID string    `json:"task_set_id"` // The task set the task was in when it failed for the last time
+	DeadAt    time.Time `json:"dead_at"`
+}
+
+// PrefixInput is used by operations that act on every task under a prefix
+type PrefixInput struct {
+	Prefix string `json:"prefix"`
+}
+
+// CancelInput selects a pending task to cancel
+type CancelInput struct {
+	TaskID string `json:"task_id"`
+}
+
+// DeadLettersInput selects dead letters by task id, or by prefix when no task ids are given
+type DeadLettersInput struct {
+	Prefix  string   `json:"prefix,omitempty"`
+	TaskIDs []string `json:"task_ids,omitempty"`
 }
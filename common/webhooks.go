@@ -0,0 +1,31 @@
+package groove
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const (
+	// WebhookSignatureHeader holds the signature of a webhook delivery body
+	WebhookSignatureHeader = "X-Groove-Signature"
+
+	// WebhookEventHeader holds the event type of a webhook delivery
+	WebhookEventHeader = "X-Groove-Event"
+
+	// WebhookDeliveryHeader holds a unique id for a webhook delivery, it does not change when a delivery is retried
+	WebhookDeliveryHeader = "X-Groove-Delivery"
+)
+
+// SignWebhook computes the signature groove sends in the WebhookSignatureHeader for a delivery body
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks that a signature taken from the WebhookSignatureHeader matches the delivery body
+func VerifyWebhook(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhook(secret, body)), []byte(signature))
+}
@@ -0,0 +1,305 @@
+package groove
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Handler processes a single task. The returned result is stored on the task when it is acked,
+// returning an error nacks the task instead and records the error on it
+type Handler func(ctx context.Context, task Task) (interface{}, error)
+
+// Worker dequeues tasks under a prefix and passes each of them to a handler, acking or nacking
+// every task on its own depending on the outcome
+type Worker struct {
+	Client  *Client
+	Prefix  string
+	Handler Handler
+
+	BatchSize         int           // The most tasks dequeued in one task set, defaults to 1
+	Concurrency       int           // The most tasks handled at once, defaults to BatchSize
+	Timeout           time.Duration // How long groove waits for a heartbeat before timing out a task set, defaults to 30s
+	HeartbeatInterval time.Duration // How often in flight task sets are extended, defaults to a third of the timeout groove granted
+	MinBackoff        time.Duration // The wait after finding no tasks, doubled on each empty dequeue. Defaults to 100ms
+	MaxBackoff        time.Duration // The longest wait between empty dequeues, defaults to 5s
+	ShutdownTimeout   time.Duration // How long in flight tasks get to finish once the worker is stopped, defaults to Timeout
+	Logger            *slog.Logger  // Defaults to slog.Default()
+}
+
+// NewWorker creates a worker that handles tasks under prefix one at a time, the returned worker
+// can be tuned by setting its fields before calling Run
+func NewWorker(client *Client, prefix string, handler Handler) *Worker {
+	return &Worker{
+		Client:  client,
+		Prefix:  prefix,
+		Handler: handler,
+	}
+}
+
+// withDefaults returns a copy of the worker with every unset option given its default
+func (w Worker) withDefaults() *Worker {
+	if w.BatchSize <= 0 {
+		w.BatchSize = 1
+	}
+
+	if w.Concurrency <= 0 {
+		w.Concurrency = w.BatchSize
+	}
+
+	if w.Timeout <= 0 {
+		w.Timeout = 30 * time.Second
+	}
+
+	if w.MinBackoff <= 0 {
+		w.MinBackoff = 100 * time.Millisecond
+	}
+
+	if w.MaxBackoff <= 0 {
+		w.MaxBackoff = 5 * time.Second
+	}
+
+	if w.MaxBackoff < w.MinBackoff {
+		w.MaxBackoff = w.MinBackoff
+	}
+
+	if w.ShutdownTimeout <= 0 {
+		w.ShutdownTimeout = w.Timeout
+	}
+
+	if w.Logger == nil {
+		w.Logger = slog.Default()
+	}
+
+	return &w
+}
+
+// Run processes tasks until ctx is cancelled. Once cancelled no more tasks are dequeued, and tasks already
+// dequeued get up to ShutdownTimeout to finish before their contexts are cancelled too. Run returns
+// once every dequeued task has been acked or nacked
+func (w *Worker) Run(ctx context.Context) error {
+	if w.Client == nil || w.Handler == nil {
+		return errors.New("worker needs a client and a handler")
+	}
+
+	cfg := w.withDefaults()
+
+	// Dequeued tasks are worked on using a context that outlives ctx, so they can finish during shutdown
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+
+	// Every task being handled holds a slot until it has been acked or nacked
+	slots := make(chan struct{}, cfg.Concurrency)
+
+	var wg sync.WaitGroup
+
+	backoff := cfg.MinBackoff
+
+	for {
+		n := acquire(ctx, slots, cfg.BatchSize)
+		if n == 0 {
+			break
+		}
+
+		// The dequeue is not cancelled with ctx, so a task set is never dequeued without being seen
+		res, err := cfg.Client.Dequeue(workCtx, DequeueTaskInput{
+			DesiredTaskCount: n,
+			Prefix:           cfg.Prefix,
+			Timeout:          int(cfg.Timeout.Milliseconds()),
+		})
+
+		got := 0
+		if err == nil {
+			got = len(res.TaskSet.Tasks)
+		}
+
+		release(slots, n-got)
+
+		if got == 0 {
+			if err != nil {
+				cfg.Logger.Warn("worker dequeue failed", slog.String("prefix", cfg.Prefix), slog.String("error", err.Error()))
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > cfg.MaxBackoff {
+				backoff = cfg.MaxBackoff
+			}
+
+			continue
+		}
+
+		backoff = cfg.MinBackoff
+
+		wg.Add(1)
+
+		go func(ts TaskSet) {
+			defer wg.Done()
+
+			cfg.process(workCtx, ts, slots)
+		}(res.TaskSet)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(cfg.ShutdownTimeout):
+		cancelWork()
+		<-done
+	}
+
+	return nil
+}
+
+// acquire waits for a free slot, then takes up to max slots without waiting. Zero is returned if ctx is done
+func acquire(ctx context.Context, slots chan struct{}, max int) int {
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return 0
+	}
+
+	// Check again, as select picks at random when ctx is done and a slot is free
+	if ctx.Err() != nil {
+		release(slots, 1)
+		return 0
+	}
+
+	n := 1
+
+	for n < max {
+		select {
+		case slots <- struct{}{}:
+			n++
+		default:
+			return n
+		}
+	}
+
+	return n
+}
+
+func release(slots chan struct{}, n int) {
+	for i := 0; i < n; i++ {
+		<-slots
+	}
+}
+
+// process handles every task in a task set concurrently, keeping the task set alive until they are all done
+func (w *Worker) process(ctx context.Context, ts TaskSet, slots chan struct{}) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go w.heartbeat(ctx, ts.ID, time.Duration(ts.Timeout)*time.Millisecond, stop)
+
+	var wg sync.WaitGroup
+
+	for _, t := range ts.Tasks {
+		wg.Add(1)
+
+		go func(t Task) {
+			defer wg.Done()
+			defer release(slots, 1)
+
+			w.handle(ctx, ts.ID, t)
+		}(t)
+	}
+
+	wg.Wait()
+}
+
+// heartbeat extends a task set until stopped. The task set is extended a third of the way into
+// each lease that groove granted, unless HeartbeatInterval is set
+func (w *Worker) heartbeat(ctx context.Context, taskSetID string, lease time.Duration, stop <-chan struct{}) {
+	timer := time.NewTimer(w.heartbeatInterval(lease))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+			res, err := w.Client.Extend(context.WithoutCancel(ctx), taskSetID, w.Timeout)
+			if err != nil {
+				w.Logger.Warn("worker heartbeat failed", slog.String("task_set_id", taskSetID), slog.String("error", err.Error()))
+			} else {
+				lease = time.Duration(res.Timeout) * time.Millisecond
+			}
+
+			timer.Reset(w.heartbeatInterval(lease))
+		}
+	}
+}
+
+// heartbeatInterval is how long to wait before extending a task set that was leased for lease
+func (w *Worker) heartbeatInterval(lease time.Duration) time.Duration {
+	if w.HeartbeatInterval > 0 {
+		return w.HeartbeatInterval
+	}
+
+	// Servers that don't report the lease are assumed to have granted the timeout that was asked for
+	if lease <= 0 {
+		lease = w.Timeout
+	}
+
+	return lease / 3
+}
+
+// handle runs the handler for a task, then acks or nacks it
+func (w *Worker) handle(ctx context.Context, taskSetID string, task Task) {
+	ctx, span := tracer.Start(task.Context(ctx), "groove process "+w.Prefix,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("groove.task_id", task.ID),
+			attribute.String("groove.task_set_id", taskSetID),
+		),
+	)
+	defer span.End()
+
+	result, err := w.call(ctx, task)
+
+	// Acks must be delivered even if the task was cancelled by a shutdown
+	ackCtx := context.WithoutCancel(ctx)
+	taskID := task.ID
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		_, err = w.Client.Nack(ackCtx, AckInput{TaskSetID: taskSetID, TaskID: &taskID, Error: err.Error()})
+	} else {
+		_, err = w.Client.Ack(ackCtx, AckInput{TaskSetID: taskSetID, TaskID: &taskID, Result: result})
+	}
+
+	if err != nil {
+		w.Logger.Error("worker could not ack task", slog.String("task_id", task.ID), slog.String("task_set_id", taskSetID), slog.String("error", err.Error()))
+	}
+}
+
+// call runs the handler, turning a panic into an error so that the task is nacked
+func (w *Worker) call(ctx context.Context, task Task) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+
+	return w.Handler(ctx, task)
+}
@@ -0,0 +1,69 @@
+package groove
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorker_HeartbeatFollowsLease(t *testing.T) {
+	var dequeued, extends int32
+
+	acked := make(chan struct{})
+
+	// The server grants a much shorter lease than the worker asks for
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dequeue", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&dequeued, 1) > 1 {
+			_ = json.NewEncoder(w).Encode(DequeueResponse{Status: "no_tasks_available"})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(DequeueResponse{Status: "ok", TaskSet: TaskSet{
+			ID:      "set",
+			Tasks:   []Task{{ID: "lease.task"}},
+			Timeout: 150,
+		}})
+	})
+	mux.HandleFunc("/extend", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&extends, 1)
+		_ = json.NewEncoder(w).Encode(ExtendResponse{Status: "ok", Timeout: 150})
+	})
+	mux.HandleFunc("/ack", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(AckResponse{Status: "ok"})
+		close(acked)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	w := NewWorker(New(srv.URL), "lease", func(ctx context.Context, task Task) (interface{}, error) {
+		time.Sleep(400 * time.Millisecond)
+		return nil, nil
+	})
+
+	w.Timeout = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = w.Run(ctx)
+	}()
+
+	select {
+	case <-acked:
+	case <-time.After(5 * time.Second):
+		t.Error("timed out waiting for the task to be acked")
+		return
+	}
+
+	// A heartbeat based on the requested timeout would not have extended the task set at all
+	if n := atomic.LoadInt32(&extends); n < 2 {
+		t.Errorf("expected the task set to be extended within each granted lease, got %d extends", n)
+	}
+}
@@ -0,0 +1,261 @@
+// Package groovetest runs an in memory groove server, so that code using the groove client
+// can be tested without running the groove binary
+package groovetest
+
+import (
+	"io"
+	"log/slog"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+	"github.com/datomar-labs-inc/groove/server"
+)
+
+// pageSize is how many tasks or prefixes are fetched at once when walking the queue
+const pageSize = 1000
+
+// Server is a groove server listening on a local httptest server
+type Server struct {
+	*httptest.Server
+
+	Groove *server.GrooveMaster
+	Client *groove.Client // A client for the server
+
+	t testing.TB
+}
+
+// NewServer starts a groove server that is closed when the test finishes.
+// Options are passed on to the GrooveMaster, for example to give it a clock that the test controls
+func NewServer(t testing.TB, opts ...server.Option) *Server {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	g := server.New(append([]server.Option{server.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)), 1)}, opts...)...)
+
+	s := &Server{
+		Server: httptest.NewServer(g.Handler()),
+		Groove: g,
+		t:      t,
+	}
+
+	s.Client = groove.New(s.URL)
+
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Close stops the http server and the GrooveMaster
+func (s *Server) Close() {
+	s.Server.Close()
+	_ = s.Groove.Close()
+}
+
+// Enqueue adds tasks to the queue, as a producer would, failing the test if they are rejected
+func (s *Server) Enqueue(tasks ...groove.Task) {
+	s.t.Helper()
+
+	err := s.Groove.Enqueue(tasks)
+	if err != nil {
+		s.t.Errorf("could not enqueue tasks: %s", err)
+	}
+}
+
+// Queued returns every task under prefix that has not finished, including tasks that are being processed.
+// An empty prefix returns every task
+func (s *Server) Queued(prefix string) []groove.Task {
+	s.t.Helper()
+
+	var tasks []groove.Task
+
+	var walk func(prefix string)
+	walk = func(prefix string) {
+		// The root container never holds tasks of its own
+		if prefix != "" {
+			for cursor := ""; ; {
+				res, err := s.Groove.BrowseTasks(prefix, cursor, pageSize, false)
+				if err != nil {
+					return
+				}
+
+				if res.LockedTask != nil {
+					tasks = append(tasks, *res.LockedTask)
+				}
+
+				tasks = append(tasks, res.Tasks...)
+
+				if cursor = res.NextCursor; cursor == "" {
+					break
+				}
+			}
+		}
+
+		for cursor := ""; ; {
+			res, err := s.Groove.BrowsePrefixes(prefix, cursor, pageSize)
+			if err != nil {
+				return
+			}
+
+			for _, child := range res.Prefixes {
+				walk(child.Prefix)
+			}
+
+			if cursor = res.NextCursor; cursor == "" {
+				break
+			}
+		}
+	}
+
+	walk(prefix)
+
+	return tasks
+}
+
+// AssertQueued fails the test unless every id is a queued task
+func (s *Server) AssertQueued(ids ...string) {
+	s.t.Helper()
+
+	queued := s.queuedIDs()
+
+	for _, id := range ids {
+		if !queued[id] {
+			s.t.Errorf("expected task %s to be queued", id)
+		}
+	}
+}
+
+// AssertNotQueued fails the test if any id is a queued task
+func (s *Server) AssertNotQueued(ids ...string) {
+	s.t.Helper()
+
+	queued := s.queuedIDs()
+
+	for _, id := range ids {
+		if queued[id] {
+			s.t.Errorf("expected task %s not to be queued", id)
+		}
+	}
+}
+
+// AssertEmpty fails the test if there are any queued tasks under prefix
+func (s *Server) AssertEmpty(prefix string) {
+	s.t.Helper()
+
+	if tasks := s.Queued(prefix); len(tasks) > 0 {
+		ids := make([]string, len(tasks))
+		for i, t := range tasks {
+			ids[i] = t.ID
+		}
+
+		s.t.Errorf("expected no tasks under %q, got %s", prefix, strings.Join(ids, ", "))
+	}
+}
+
+func (s *Server) queuedIDs() map[string]bool {
+	ids := map[string]bool{}
+
+	for _, t := range s.Queued("") {
+		ids[t.ID] = true
+	}
+
+	return ids
+}
+
+// InFlight lists the task sets that have been dequeued but not finished
+func (s *Server) InFlight() []groove.TaskSetLog {
+	return s.Groove.TaskSets(math.MaxInt)
+}
+
+// TimeoutTaskSet times out a task set straight away, as if its worker had stopped responding
+func (s *Server) TimeoutTaskSet(taskSetID string) {
+	s.t.Helper()
+
+	err := s.Groove.Timeout(taskSetID)
+	if err != nil {
+		s.t.Errorf("could not time out task set %s: %s", taskSetID, err)
+	}
+}
+
+// TimeoutAll times out every in flight task set, returning how many were timed out
+func (s *Server) TimeoutAll() int {
+	s.t.Helper()
+
+	sets := s.InFlight()
+
+	for _, ts := range sets {
+		s.TimeoutTaskSet(ts.ID)
+	}
+
+	return len(sets)
+}
+
+// Events returns the events recorded so far, filtered to the given types if any are given.
+// Only the most recent few thousand events are kept
+func (s *Server) Events(types ...groove.EventType) []groove.Event {
+	events, _, _ := s.Groove.Events(0)
+
+	if len(types) == 0 {
+		return events
+	}
+
+	var filtered []groove.Event
+
+	for _, e := range events {
+		for _, et := range types {
+			if e.Type == et {
+				filtered = append(filtered, e)
+				break
+			}
+		}
+	}
+
+	return filtered
+}
+
+// Result returns the task as it was when it last finished, either by being acked or by running out of retries
+func (s *Server) Result(taskID string) (groove.Task, bool) {
+	events, _, _ := s.Groove.Events(0)
+
+	return lastResult(events, taskID)
+}
+
+// WaitForResult waits for a task to finish and returns it, failing the test if it does not finish within timeout.
+// WaitForResult must be called from the goroutine running the test
+func (s *Server) WaitForResult(taskID string, timeout time.Duration) groove.Task {
+	s.t.Helper()
+
+	deadline := time.After(timeout)
+
+	for {
+		events, _, wait := s.Groove.Events(0)
+
+		if task, ok := lastResult(events, taskID); ok {
+			return task
+		}
+
+		select {
+		case <-wait:
+		case <-deadline:
+			s.t.Fatalf("task %s did not finish within %s", taskID, timeout)
+		}
+	}
+}
+
+func lastResult(events []groove.Event, taskID string) (groove.Task, bool) {
+	for i := len(events) - 1; i >= 0; i-- {
+		e := events[i]
+
+		if e.TaskID == taskID && (e.Type == groove.EventAcked || e.Type == groove.EventDeadLettered) {
+			return e.Task, true
+		}
+	}
+
+	return groove.Task{}, false
+}
@@ -0,0 +1,154 @@
+package groovetest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+	"github.com/datomar-labs-inc/groove/server"
+)
+
+func TestServer_Drain(t *testing.T) {
+	s := NewServer(t)
+
+	s.Enqueue(groove.Task{ID: "drain.work.a.1"}, groove.Task{ID: "drain.work.b.1"})
+
+	release := make(chan struct{})
+
+	w := groove.NewWorker(s.Client, "drain.work", func(ctx context.Context, task groove.Task) (interface{}, error) {
+		<-release
+		return task.ID, nil
+	})
+
+	w.Concurrency = 2
+	w.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = w.Run(ctx)
+	}()
+
+	for deadline := time.Now().Add(5 * time.Second); len(s.InFlight()) < 2; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Error("expected both tasks to be dequeued")
+			return
+		}
+	}
+
+	waitErr := make(chan error, 1)
+
+	go func() {
+		_, err := s.Client.Enqueue(context.Background(), []groove.Task{{ID: "drain.wait.1"}}, true)
+		waitErr <- err
+	}()
+
+	drainErr := make(chan error, 1)
+
+	go func() {
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelDrain()
+
+		drainErr <- s.Groove.Drain(drainCtx)
+	}()
+
+	for !s.Groove.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err := s.Client.Dequeue(context.Background(), groove.DequeueTaskInput{DesiredTaskCount: 1, Prefix: "drain.wait", Timeout: 60000})
+	if err == nil || !strings.Contains(err.Error(), "shutting down") {
+		t.Errorf("expected dequeues to be refused while draining, got %v", err)
+		return
+	}
+
+	// The drain waits for the in flight task sets to be acked
+	select {
+	case err := <-drainErr:
+		t.Errorf("expected the drain to wait for the in flight task sets, got %v", err)
+		return
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+
+	select {
+	case err := <-drainErr:
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("drain did not finish")
+		return
+	}
+
+	for _, id := range []string{"drain.work.a.1", "drain.work.b.1"} {
+		if task, ok := s.Result(id); !ok || !task.Succeeded {
+			t.Errorf("expected %s to have been acked, got %+v", id, task)
+		}
+	}
+
+	// The waiting request is released, and its task is kept for after the restart
+	select {
+	case err := <-waitErr:
+		if err == nil || !strings.Contains(err.Error(), "shutting down") {
+			t.Errorf("expected the waiting enqueue to be released, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the waiting enqueue was not released")
+	}
+}
+
+func TestServer_DrainDeadline(t *testing.T) {
+	storage := server.NewFileStorage(filepath.Join(t.TempDir(), "groove.json"))
+
+	s := NewServer(t, server.WithStorage(storage, time.Hour))
+
+	s.Enqueue(groove.Task{ID: "deadline.a.1"})
+
+	_, err := s.Client.Dequeue(context.Background(), groove.DequeueTaskInput{DesiredTaskCount: 1, Prefix: "deadline", Timeout: 60000})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err = s.Groove.Drain(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the drain to give up on the unacked task set, got %v", err)
+		return
+	}
+
+	s.Close()
+
+	// The task that was never acked is handed out again after a restart
+	restarted := NewServer(t)
+	restarted.Groove.Restore(mustLoad(t, storage))
+
+	restarted.AssertQueued("deadline.a.1")
+
+	if len(restarted.InFlight()) != 0 {
+		t.Errorf("expected no task sets in flight after a restart, got %d", len(restarted.InFlight()))
+	}
+}
+
+func mustLoad(t *testing.T, storage server.Storage) *server.Snapshot {
+	t.Helper()
+
+	snapshot, err := storage.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return snapshot
+}
@@ -0,0 +1,141 @@
+package groovetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func TestWorker(t *testing.T) {
+	s := NewServer(t)
+
+	var ids []string
+
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("worker.%d.task", i)
+
+		s.Enqueue(groove.Task{ID: id})
+		ids = append(ids, id)
+	}
+
+	// A slow task that must be kept alive by heartbeats
+	s.Enqueue(groove.Task{ID: "worker.slow.task"})
+	ids = append(ids, "worker.slow.task")
+
+	s.AssertQueued(ids...)
+
+	var running, maxRunning int32
+
+	w := groove.NewWorker(s.Client, "worker", func(ctx context.Context, task groove.Task) (interface{}, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+
+		if task.ID == "worker.slow.task" {
+			time.Sleep(time.Second)
+		}
+
+		if task.ID == "worker.3.task" {
+			return nil, errors.New("failed")
+		}
+
+		return task.ID, nil
+	})
+
+	w.BatchSize = 4
+	w.Concurrency = 6
+	w.Timeout = 300 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+
+	go func() {
+		done <- w.Run(ctx)
+	}()
+
+	for _, id := range ids {
+		task := s.WaitForResult(id, 10*time.Second)
+
+		if id == "worker.3.task" {
+			if task.Succeeded || len(task.Errors) == 0 {
+				t.Errorf("expected %s to fail, got %+v", id, task)
+			}
+		} else if !task.Succeeded || task.Result != id {
+			t.Errorf("expected %s to succeed, got %+v", id, task)
+		}
+	}
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("worker did not stop")
+		return
+	}
+
+	if maxRunning > 6 {
+		t.Errorf("expected at most 6 tasks to run at once, got %d", maxRunning)
+	}
+
+	if timeouts := s.Events(groove.EventTimedOut); len(timeouts) != 0 {
+		t.Errorf("expected heartbeats to stop the slow task timing out, got %d timeouts", len(timeouts))
+	}
+
+	if len(s.InFlight()) != 0 {
+		t.Errorf("expected no task sets left in flight, got %d", len(s.InFlight()))
+	}
+
+	s.AssertEmpty("worker")
+}
+
+func TestServer_TimeoutAll(t *testing.T) {
+	s := NewServer(t)
+
+	s.Enqueue(groove.Task{ID: "timeout.a.1", RetryThreshold: 1}, groove.Task{ID: "timeout.b.1"})
+
+	res, err := s.Client.Dequeue(context.Background(), groove.DequeueTaskInput{DesiredTaskCount: 2, Prefix: "timeout", Timeout: 60000})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(res.TaskSet.Tasks) != 2 {
+		t.Errorf("expected 2 tasks to be dequeued, got %d", len(res.TaskSet.Tasks))
+		return
+	}
+
+	if n := s.TimeoutAll(); n != 1 {
+		t.Errorf("expected 1 task set to be timed out, got %d", n)
+		return
+	}
+
+	// The task with retries left goes back on the queue, the other has failed for good
+	s.AssertQueued("timeout.a.1")
+	s.AssertNotQueued("timeout.b.1")
+
+	if task, ok := s.Result("timeout.b.1"); !ok || task.Succeeded {
+		t.Errorf("expected timeout.b.1 to have failed, got %+v", task)
+	}
+
+	if _, ok := s.Result("timeout.a.1"); ok {
+		t.Error("expected timeout.a.1 not to have finished")
+	}
+}
@@ -1,44 +1,223 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/gin-gonic/gin"
+	groove "github.com/datomar-labs-inc/groove/common"
+	"github.com/datomar-labs-inc/groove/server"
 )
 
-var grooveMaster *GrooveMaster
-
 func main() {
-	grooveMaster = New()
+	// Settings come from the CONFIG_FILE json file, overridden by environment variables
+	cfg, err := server.LoadConfig(os.Getenv("CONFIG_FILE"), os.Getenv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid config:", err)
+		os.Exit(1)
+	}
 
-	r := gin.Default()
+	logger, sampleRate, err := server.NewLogger(os.Stdout, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	r.POST("/dequeue", hDequeue)
-	r.POST("/enqueue", hEnqueue)
-	r.POST("/ack", hAck)
-	r.POST("/nack", hNack)
+	slog.SetDefault(logger)
 
-	r.GET("/status", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": grooveMaster.RootContainer.String()})
-	})
+	shutdownTracing, err := server.SetupTracing(context.Background())
+	if err != nil {
+		logger.Error("failed to setup tracing", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	defer func() {
+		_ = shutdownTracing(context.Background())
+	}()
 
-	r.GET("/data", func(c *gin.Context) {
-		grooveMaster.mx.Lock()
-		defer grooveMaster.mx.Unlock()
+	opts := []server.Option{server.WithLogger(logger, sampleRate), server.WithConfig(cfg)}
 
-		c.JSON(http.StatusOK, grooveMaster.RootContainer)
+	// Authentication is off unless api keys are given
+	if cfg.APIKeysFile != "" {
+		keys, err := loadAPIKeys(cfg.APIKeysFile)
+		if err != nil {
+			logger.Error("failed to load api keys", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+
+		opts = append(opts, server.WithAPIKeys(keys))
+	}
+
+	namespaces := server.NewNamespaces(logger, sampleRate, func(name string) []server.Option {
+		nsOpts := append([]server.Option{}, opts...)
+
+		// The queue is only kept in memory unless a snapshot path is given
+		if cfg.SnapshotPath != "" {
+			nsOpts = append(nsOpts, server.WithStorage(server.NewFileStorage(snapshotPath(cfg.SnapshotPath, name)), time.Duration(cfg.SnapshotInterval)))
+		}
+
+		return nsOpts
 	})
 
-	port := "9854"
+	// Only the default namespace exists unless more are given
+	quotas := map[string]groove.Quota{}
+
+	if cfg.NamespacesFile != "" {
+		quotas, err = loadNamespaces(cfg.NamespacesFile)
+		if err != nil {
+			logger.Error("failed to load namespaces", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	if _, ok := quotas[groove.DefaultNamespace]; !ok {
+		quotas[groove.DefaultNamespace] = groove.Quota{}
+	}
+
+	for name, quota := range quotas {
+		_, err = namespaces.Create(name, quota)
+		if err != nil {
+			logger.Error("failed to start groove", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	logger.Info("groove listening", slog.String("addr", cfg.ListenAddress), slog.Bool("tls", cfg.TLS.CertFile != ""))
+
+	srv := &http.Server{
+		Addr:    cfg.ListenAddress,
+		Handler: namespaces.Handler(),
+	}
+
+	// Plain http is served unless a certificate is given
+	if cfg.TLS.CertFile != "" {
+		var certs *server.CertReloader
+
+		certs, err = server.NewCertReloader(cfg.TLS)
+		if err != nil {
+			logger.Error("failed to load tls certificates", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+
+		srv.TLSConfig = certs.TLSConfig()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+
+	go func() {
+		if srv.TLSConfig != nil {
+			serveErr <- srv.ListenAndServeTLS("", "")
+		} else {
+			serveErr <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err = <-serveErr:
+		logger.Error("server stopped", slog.String("error", err.Error()))
+
+		closeErr := namespaces.Close()
+		if closeErr != nil {
+			logger.Error("failed to close groove", slog.String("error", closeErr.Error()))
+		}
+
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	// A second signal exits straight away
+	stop()
 
-	if os.Getenv("PORT") != "" {
-		port = os.Getenv("PORT")
+	shutdown(logger, srv, namespaces, time.Duration(cfg.ShutdownTimeout))
+}
+
+// shutdown stops groove gracefully. Dequeues are stopped first while acks are still accepted, so that workers
+// can finish the task sets they have, then the http server finishes the requests it is handling and the
+// queue is saved one last time
+func shutdown(logger *slog.Logger, srv *http.Server, namespaces *server.Namespaces, timeout time.Duration) {
+	logger.Info("shutting down", slog.Duration("timeout", timeout))
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), timeout)
+	defer cancelDrain()
+
+	err := namespaces.Drain(drainCtx)
+	if err != nil {
+		logger.Warn("task sets did not finish before shutting down, they will be handed out again on restart", slog.String("error", err.Error()))
+	}
+
+	httpCtx, cancelHTTP := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelHTTP()
+
+	err = srv.Shutdown(httpCtx)
+	if err != nil {
+		logger.Error("failed to shut down the http server", slog.String("error", err.Error()))
 	}
 
-	err := r.Run(fmt.Sprintf("0.0.0.0:%s", port))
+	err = namespaces.Close()
+	if err != nil {
+		logger.Error("failed to close groove", slog.String("error", err.Error()))
+	}
+
+	logger.Info("groove stopped")
+}
+
+// loadNamespaces reads a json object of namespace names to their quotas
+func loadNamespaces(path string) (map[string]groove.Quota, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var quotas map[string]groove.Quota
+
+	err = json.Unmarshal(b, &quotas)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+
+	if quotas == nil {
+		quotas = map[string]groove.Quota{}
+	}
+
+	return quotas, nil
+}
+
+// snapshotPath is where a namespace keeps its snapshot. The default namespace uses the path as given,
+// other namespaces add their name before the extension, so groove.json becomes groove.team-a.json
+func snapshotPath(path string, namespace string) string {
+	if namespace == groove.DefaultNamespace {
+		return path
+	}
+
+	ext := filepath.Ext(path)
+
+	return strings.TrimSuffix(path, ext) + "." + namespace + ext
+}
+
+// loadAPIKeys reads a json array of api keys
+func loadAPIKeys(path string) ([]groove.APIKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []groove.APIKey
+
+	err = json.Unmarshal(b, &keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, server.ValidateAPIKeys(keys)
 }
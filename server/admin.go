@@ -0,0 +1,304 @@
+package server
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// Pause stops tasks under prefix from being dequeued until the prefix is resumed. An empty prefix pauses everything
+func (g *GrooveMaster) Pause(prefix string) {
+	g.setPaused(prefix, true)
+}
+
+// Resume allows tasks under a paused prefix to be dequeued again
+func (g *GrooveMaster) Resume(prefix string) {
+	g.setPaused(prefix, false)
+}
+
+func (g *GrooveMaster) setPaused(prefix string, paused bool) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	if paused {
+		g.paused[prefix] = true
+	} else {
+		delete(g.paused, prefix)
+	}
+
+	tc := g.RootContainer
+
+	if prefix != "" {
+		tc, _ = g.RootContainer.GetChildContainer(prefix)
+	}
+
+	if tc != nil {
+		tc.Paused = paused
+	}
+}
+
+// Paused lists the paused prefixes
+func (g *GrooveMaster) Paused() []string {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	prefixes := []string{}
+
+	for p := range g.paused {
+		prefixes = append(prefixes, p)
+	}
+
+	sort.Strings(prefixes)
+
+	return prefixes
+}
+
+// Cancel removes a pending task from the queue, anyone waiting on the task is told it failed.
+// Tasks that are currently being processed cannot be cancelled
+func (g *GrooveMaster) Cancel(taskID string) error {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	idParts := strings.Split(taskID, ".")
+	if len(idParts) < 2 {
+		return errors.New("task did not exist")
+	}
+
+	cc, _ := g.RootContainer.GetChildContainer(strings.Join(idParts[:len(idParts)-1], "."))
+	if cc == nil {
+		return errors.New("task did not exist")
+	}
+
+	cancelled := cc.removeTasks(func(t groove.Task) bool {
+		return t.ID == taskID
+	})
+
+	if len(cancelled) == 0 {
+		if cc.Locked && cc.LockedTask.ID == taskID {
+			return errors.New("task is being processed and cannot be cancelled")
+		}
+
+		return errors.New("task did not exist")
+	}
+
+	for _, t := range cancelled {
+		t.Errors = append(t.Errors, "task was cancelled")
+		g.finishTask(t)
+	}
+
+	cc.prune()
+
+	return nil
+}
+
+// Purge removes every pending task under prefix, returning how many were removed.
+// Tasks that are currently being processed are left alone
+func (g *GrooveMaster) Purge(prefix string) (int, error) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	tc := g.RootContainer
+
+	if prefix != "" {
+		tc, _ = g.RootContainer.GetChildContainer(prefix)
+		if tc == nil {
+			return 0, errPrefixNotFound
+		}
+	}
+
+	var purged int
+
+	var walk func(tc *TaskContainer)
+	walk = func(tc *TaskContainer) {
+		for _, child := range tc.Children {
+			walk(child)
+		}
+
+		removed := tc.removeTasks(func(groove.Task) bool {
+			return true
+		})
+
+		for _, t := range removed {
+			t.Errors = append(t.Errors, "task was purged")
+			g.finishTask(t)
+		}
+
+		purged += len(removed)
+
+		tc.prune()
+	}
+
+	walk(tc)
+
+	return purged, nil
+}
+
+// TaskSets lists up to limit in flight task sets, soonest timeout first
+func (g *GrooveMaster) TaskSets(limit int) []groove.TaskSetLog {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	sets := make([]groove.TaskSetLog, 0, len(g.TaskSetLogs))
+
+	for _, ts := range g.TaskSetLogs {
+		sets = append(sets, ts)
+	}
+
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i].TimeoutAt.Before(sets[j].TimeoutAt)
+	})
+
+	if len(sets) > limit {
+		sets = sets[:limit]
+	}
+
+	return sets
+}
+
+// deadLetter records a task that has failed more times than its retry threshold allows
+// deadLetter is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) deadLetter(taskSetID string, task groove.Task) {
+	g.emit(groove.EventDeadLettered, taskSetID, task)
+
+	if !g.deadLettersEnabled(task.ID) {
+		return
+	}
+
+	if len(g.deadLetters) >= g.limits.MaxDeadLetters {
+		g.deadLetters = g.deadLetters[1:]
+	}
+
+	g.deadLetters = append(g.deadLetters, groove.DeadLetter{
+		Task:      task,
+		TaskSetID: taskSetID,
+		DeadAt:    g.clock.Now(),
+	})
+}
+
+// DeadLetters lists up to limit dead letters for tasks under prefix, oldest first
+func (g *GrooveMaster) DeadLetters(prefix string, limit int) []groove.DeadLetter {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	dls := []groove.DeadLetter{}
+
+	for _, dl := range g.deadLetters {
+		if len(dls) >= limit {
+			break
+		}
+
+		if hasPrefix(dl.Task.ID, prefix) {
+			dls = append(dls, dl)
+		}
+	}
+
+	return dls
+}
+
+// RetryDeadLetters enqueues dead lettered tasks again with a fresh retry count. Tasks are selected by id,
+// or by prefix when no ids are given. The number of tasks retried is returned. If the tasks cannot be
+// enqueued, an error is returned and they are left as dead letters
+func (g *GrooveMaster) RetryDeadLetters(prefix string, taskIDs []string) (int, error) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	retried, rest := g.splitDeadLetters(prefix, taskIDs)
+
+	tasks := make([]groove.Task, len(retried))
+
+	for i, dl := range retried {
+		t := dl.Task
+		t.RetryCount = 0
+		t.Errors = nil
+		t.Succeeded = false
+
+		tasks[i] = t
+	}
+
+	// Retried tasks are admitted like any other enqueue, which also takes them out of their batch.
+	// The batch was already told about the failure, a retried task is on its own
+	tasks, err := g.admit(tasks)
+	if err != nil {
+		return 0, err
+	}
+
+	g.deadLetters = rest
+
+	for _, t := range tasks {
+		g.putTask(t)
+	}
+
+	return len(retried), nil
+}
+
+// PurgeDeadLetters deletes dead letters, selected the same way as RetryDeadLetters
+func (g *GrooveMaster) PurgeDeadLetters(prefix string, taskIDs []string) int {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	purged, rest := g.splitDeadLetters(prefix, taskIDs)
+	g.deadLetters = rest
+
+	return len(purged)
+}
+
+// splitDeadLetters divides the dead letters into the ones that are selected and the rest, leaving the dead letters unchanged
+// splitDeadLetters is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) splitDeadLetters(prefix string, taskIDs []string) (selected []groove.DeadLetter, rest []groove.DeadLetter) {
+	ids := map[string]bool{}
+	for _, id := range taskIDs {
+		ids[id] = true
+	}
+
+	for _, dl := range g.deadLetters {
+		match := hasPrefix(dl.Task.ID, prefix)
+		if len(ids) > 0 {
+			match = ids[dl.Task.ID]
+		}
+
+		if match {
+			selected = append(selected, dl)
+		} else {
+			rest = append(rest, dl)
+		}
+	}
+
+	return selected, rest
+}
+
+// removeTasks removes the pending tasks that match from the container, returning them
+func (t *TaskContainer) removeTasks(match func(groove.Task) bool) []groove.Task {
+	var removed []groove.Task
+	kept := t.Tasks[:0]
+
+	for _, task := range t.Tasks {
+		if match(task) {
+			removed = append(removed, task)
+		} else {
+			kept = append(kept, task)
+		}
+	}
+
+	t.Tasks = kept
+
+	for _, task := range removed {
+		t.addPayload(-task.PayloadSize)
+
+		for n := t; n != nil; n = n.Parent {
+			n.pending--
+
+			if !n.oldestDirty && !task.EnqueuedAt.After(n.oldest) {
+				n.oldestDirty = true
+			}
+		}
+	}
+
+	return removed
+}
+
+// hasPrefix checks if a task id is under a prefix, an empty prefix matches everything
+func hasPrefix(taskID string, prefix string) bool {
+	return prefix == "" || strings.HasPrefix(taskID, prefix+".")
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func TestGrooveMaster_Pause(t *testing.T) {
+	g := New()
+
+	g.Enqueue([]groove.Task{
+		{ID: "pause.a.1"},
+		{ID: "pause.b.1"},
+	})
+
+	g.Pause("pause.a")
+
+	ts := g.Dequeue(10, "pause", 10*time.Second)
+	if ts == nil || len(ts.Tasks) != 1 || ts.Tasks[0].ID != "pause.b.1" {
+		t.Errorf("expected only pause.b.1 to be dequeued while pause.a is paused, got %+v", ts)
+		return
+	}
+
+	if ts := g.Dequeue(10, "pause.a", 10*time.Second); ts != nil {
+		t.Errorf("expected nothing to be dequeued from a paused prefix, got %+v", ts)
+		return
+	}
+
+	// Tasks enqueued after pausing must land in a paused container too
+	g.Pause("pause.c")
+	g.Enqueue([]groove.Task{{ID: "pause.c.1"}})
+
+	if ts := g.Dequeue(10, "pause.c", 10*time.Second); ts != nil {
+		t.Errorf("expected nothing to be dequeued from a prefix paused before it existed, got %+v", ts)
+		return
+	}
+
+	g.Resume("pause.a")
+	g.Resume("pause.c")
+
+	if paused := g.Paused(); len(paused) != 0 {
+		t.Errorf("expected no paused prefixes, got %v", paused)
+		return
+	}
+
+	ts = g.Dequeue(10, "pause", 10*time.Second)
+	if ts == nil || len(ts.Tasks) != 2 {
+		t.Errorf("expected both resumed tasks to be dequeued, got %+v", ts)
+		return
+	}
+}
+
+func TestGrooveMaster_CancelAndPurge(t *testing.T) {
+	g := New()
+
+	g.Enqueue([]groove.Task{
+		{ID: "purge.a.1"},
+		{ID: "purge.a.2"},
+		{ID: "purge.a.3"},
+		{ID: "purge.a.nested.1"},
+		{ID: "purge.b.1"},
+	})
+
+	ts := g.Dequeue(1, "purge.a", 10*time.Second)
+	if ts == nil {
+		t.Error("expected a task set")
+		return
+	}
+
+	locked := ts.Tasks[0].ID
+
+	if err := g.Cancel(locked); err == nil {
+		t.Error("expected cancelling a locked task to fail")
+		return
+	}
+
+	if err := g.Cancel("purge.a.missing"); err == nil {
+		t.Error("expected cancelling a missing task to fail")
+		return
+	}
+
+	var cancelled string
+	for _, id := range []string{"purge.a.1", "purge.a.2"} {
+		if id != locked {
+			cancelled = id
+			break
+		}
+	}
+
+	if err := g.Cancel(cancelled); err != nil {
+		t.Error(err)
+		return
+	}
+
+	checkCounters(t, g.RootContainer, "")
+
+	purged, err := g.Purge("purge.a")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Only the locked task is left under purge.a
+	if purged != 2 {
+		t.Errorf("expected 2 tasks to be purged, got %d", purged)
+		return
+	}
+
+	checkCounters(t, g.RootContainer, "")
+
+	if g.RootContainer.pending != 1 {
+		t.Errorf("expected only purge.b.1 to be pending, got %d pending", g.RootContainer.pending)
+		return
+	}
+
+	if _, err := g.Purge("purge.missing"); err != errPrefixNotFound {
+		t.Errorf("expected purging a missing prefix to fail, got %v", err)
+		return
+	}
+}
+
+func TestGrooveMaster_DeadLetters(t *testing.T) {
+	g := New()
+
+	g.Enqueue([]groove.Task{
+		{ID: "dlq.a.1"},
+		{ID: "dlq.b.1"},
+	})
+
+	failed := g.Dequeue(10, "dlq", 10*time.Second)
+	if failed == nil {
+		t.Error("expected a task set")
+		return
+	}
+
+	err := g.Nack(failed.ID, "failed")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if dls := g.DeadLetters("", 100); len(dls) != 2 {
+		t.Errorf("expected 2 dead letters, got %d", len(dls))
+		return
+	}
+
+	if dls := g.DeadLetters("dlq.a", 100); len(dls) != 1 || dls[0].Task.ID != "dlq.a.1" {
+		t.Errorf("expected only dlq.a.1 to be dead lettered under dlq.a, got %+v", dls)
+		return
+	}
+
+	retried, err := g.RetryDeadLetters("dlq.a", nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if retried != 1 {
+		t.Errorf("expected 1 dead letter to be retried, got %d", retried)
+		return
+	}
+
+	checkCounters(t, g.RootContainer, "")
+
+	ts := g.Dequeue(10, "dlq", 10*time.Second)
+	if ts == nil || len(ts.Tasks) != 1 || ts.Tasks[0].ID != "dlq.a.1" || ts.Tasks[0].RetryCount != 0 {
+		t.Errorf("expected dlq.a.1 to be dequeued with a fresh retry count, got %+v", ts)
+		return
+	}
+
+	if purged := g.PurgeDeadLetters("", []string{"dlq.b.1"}); purged != 1 {
+		t.Errorf("expected 1 dead letter to be purged, got %d", purged)
+		return
+	}
+
+	if dls := g.DeadLetters("", 100); len(dls) != 0 {
+		t.Errorf("expected no dead letters, got %+v", dls)
+		return
+	}
+}
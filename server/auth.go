@@ -0,0 +1,359 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+const (
+	// maxSignatureAge is how far the timestamp of a signed request can be from the current time.
+	// Nonces are remembered for as long as their request could be accepted, which is up to twice this long
+	maxSignatureAge = 5 * time.Minute
+
+	// maxNonceLength is the longest nonce a signed request can have, as every nonce is kept in memory for a while
+	maxNonceLength = 64
+
+	// maxSignedBodyBytes is the largest body a signed request can have, it has to be read before the signature is checked
+	maxSignedBodyBytes = 16 << 20
+
+	// apiKeyContextKey is where the authenticated api key is kept on the gin context
+	apiKeyContextKey = "groove_api_key"
+)
+
+// apiKeys looks up api keys by id, by a hash of their secret for bearer authentication,
+// and by the identity of their client certificate for mutual tls
+type apiKeys struct {
+	byID     map[string]*groove.APIKey
+	bySecret map[[sha256.Size]byte]*groove.APIKey
+	byCert   map[string]*groove.APIKey
+
+	nonces nonceCache
+}
+
+// nonceCache remembers the nonces of signed requests until their timestamps are too old to be accepted,
+// so that a signed request cannot be used twice
+type nonceCache struct {
+	mx      sync.Mutex
+	nonces  map[string]time.Time // Keyed by api key id and nonce, with the time the nonce can be forgotten
+	cleanAt time.Time            // When forgotten nonces are next removed
+}
+
+// use records a nonce, returning false if it has already been used
+func (n *nonceCache) use(keyID string, nonce string, forgetAt time.Time, now time.Time) bool {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	if n.nonces == nil {
+		n.nonces = map[string]time.Time{}
+	}
+
+	if now.After(n.cleanAt) {
+		for k, t := range n.nonces {
+			if now.After(t) {
+				delete(n.nonces, k)
+			}
+		}
+
+		n.cleanAt = now.Add(time.Minute)
+	}
+
+	k := keyID + "\n" + nonce
+
+	if _, ok := n.nonces[k]; ok {
+		return false
+	}
+
+	n.nonces[k] = forgetAt
+
+	return true
+}
+
+func newAPIKeys(keys []groove.APIKey) (*apiKeys, error) {
+	ak := &apiKeys{
+		byID:     map[string]*groove.APIKey{},
+		bySecret: map[[sha256.Size]byte]*groove.APIKey{},
+		byCert:   map[string]*groove.APIKey{},
+	}
+
+	for i := range keys {
+		k := &keys[i]
+
+		if k.ID == "" || (k.Secret == "" && k.ClientCertificate == "") {
+			return nil, fmt.Errorf("api key %d needs an id, and a secret or a client certificate", i)
+		}
+
+		if _, ok := ak.byID[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate api key id %q", k.ID)
+		}
+
+		for _, p := range k.Permissions {
+			if p != groove.PermissionEnqueue && p != groove.PermissionDequeue && p != groove.PermissionRead && p != groove.PermissionAdmin {
+				return nil, fmt.Errorf("api key %q has unknown permission %q", k.ID, p)
+			}
+		}
+
+		ak.byID[k.ID] = k
+
+		if k.Secret != "" {
+			ak.bySecret[sha256.Sum256([]byte(k.Secret))] = k
+		}
+
+		if k.ClientCertificate != "" {
+			if _, ok := ak.byCert[k.ClientCertificate]; ok {
+				return nil, fmt.Errorf("duplicate api key client certificate %q", k.ClientCertificate)
+			}
+
+			ak.byCert[k.ClientCertificate] = k
+		}
+	}
+
+	return ak, nil
+}
+
+// WithAPIKeys turns on authentication, every request must then use one of the keys.
+// WithAPIKeys panics if the keys are invalid, use ValidateAPIKeys to check them first
+func WithAPIKeys(keys []groove.APIKey) Option {
+	return func(g *GrooveMaster) {
+		ak, err := newAPIKeys(keys)
+		if err != nil {
+			panic(err)
+		}
+
+		g.apiKeys = ak
+	}
+}
+
+// ValidateAPIKeys checks that api keys can be used with WithAPIKeys
+func ValidateAPIKeys(keys []groove.APIKey) error {
+	_, err := newAPIKeys(keys)
+	return err
+}
+
+// authenticate identifies the api key used for a request, either from a bearer token, a request signature
+// or a verified client certificate. Requests without a valid key are rejected. Nothing is checked when authentication is off
+func (g *GrooveMaster) authenticate(c *gin.Context) {
+	if g.apiKeys == nil {
+		return
+	}
+
+	var key *groove.APIKey
+	var err error
+
+	if c.GetHeader(groove.RequestSignatureHeader) != "" {
+		key, err = g.verifySignature(c)
+	} else if token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "); token != "" && token != c.GetHeader("Authorization") {
+		key = g.apiKeys.bySecret[sha256.Sum256([]byte(token))]
+		if key == nil {
+			err = fmt.Errorf("invalid api key")
+		}
+	} else if key = g.certificateKey(c); key == nil {
+		err = fmt.Errorf("an api key is required")
+	}
+
+	var tooLarge *http.MaxBytesError
+
+	if errors.As(err, &tooLarge) {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("signed requests cannot have a body larger than %d bytes", tooLarge.Limit)})
+		return
+	}
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Set(apiKeyContextKey, key)
+}
+
+func (g *GrooveMaster) verifySignature(c *gin.Context) (*groove.APIKey, error) {
+	key := g.apiKeys.byID[c.GetHeader(groove.KeyIDHeader)]
+	if key == nil || key.Secret == "" {
+		return nil, fmt.Errorf("invalid api key")
+	}
+
+	ts, err := strconv.ParseInt(c.GetHeader(groove.TimestampHeader), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request timestamp")
+	}
+
+	timestamp := time.Unix(ts, 0)
+	now := g.clock.Now()
+
+	if age := now.Sub(timestamp); age > maxSignatureAge || age < -maxSignatureAge {
+		return nil, fmt.Errorf("request timestamp is too far from the current time")
+	}
+
+	nonce := c.GetHeader(groove.NonceHeader)
+	if nonce == "" || len(nonce) > maxNonceLength {
+		return nil, fmt.Errorf("signed requests need a nonce of up to %d characters", maxNonceLength)
+	}
+
+	var body []byte
+
+	if c.Request.Body != nil {
+		body, err = io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxSignedBodyBytes))
+		if err != nil {
+			return nil, err
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	// The namespace is signed too, so a request cannot be moved to another namespace
+	expected := groove.SignRequest(key.Secret, c.Request.Method, c.Request.URL.RequestURI(), g.namespace, timestamp, nonce, body)
+
+	if !hmac.Equal([]byte(expected), []byte(c.GetHeader(groove.RequestSignatureHeader))) {
+		return nil, fmt.Errorf("invalid request signature")
+	}
+
+	// The nonce is only recorded once the signature is known to be good, so nobody else can use it up
+	if !g.apiKeys.nonces.use(key.ID, nonce, timestamp.Add(maxSignatureAge), now) {
+		return nil, fmt.Errorf("request has already been used")
+	}
+
+	return key, nil
+}
+
+// certificateKey returns the api key matching the verified client certificate of a request, if there is one
+func (g *GrooveMaster) certificateKey(c *gin.Context) *groove.APIKey {
+	state := c.Request.TLS
+	if state == nil || len(state.VerifiedChains) == 0 {
+		return nil
+	}
+
+	for _, id := range certificateIdentities(state.VerifiedChains[0][0]) {
+		if key := g.apiKeys.byCert[id]; key != nil {
+			return key
+		}
+	}
+
+	return nil
+}
+
+// authorize checks that the api key of a request has a permission, and that every target (a task id or prefix)
+// is under one of its prefixes. An empty target is the whole queue. The request is rejected if not
+func (g *GrooveMaster) authorize(c *gin.Context, perm groove.Permission, targets ...string) bool {
+	key := requestKey(c)
+	if key == nil {
+		if g.apiKeys != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "an api key is required"})
+			return false
+		}
+
+		return true
+	}
+
+	if !inNamespace(key, g.namespace) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("api key %s cannot access namespace %s", key.ID, g.namespace)})
+		return false
+	}
+
+	if !hasPermission(key, perm) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("api key %s does not have the %s permission", key.ID, perm)})
+		return false
+	}
+
+	for _, target := range targets {
+		if !inScope(key, target) {
+			if target == "" {
+				target = "the whole queue"
+			}
+
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("api key %s cannot access %s", key.ID, target)})
+			return false
+		}
+	}
+
+	return true
+}
+
+// authorizeTaskSet checks that a task set was dequeued by the api key of a request. Admins can act on any task set
+func (g *GrooveMaster) authorizeTaskSet(c *gin.Context, taskSetID string) bool {
+	key := requestKey(c)
+	if key == nil || hasPermission(key, groove.PermissionAdmin) {
+		return true
+	}
+
+	g.mx.Lock()
+	ts, ok := g.TaskSetLogs[taskSetID]
+	g.mx.Unlock()
+
+	// Missing task sets are reported by the operation itself
+	if ok && ts.Owner != key.ID {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("task set %s was dequeued by another api key", taskSetID)})
+		return false
+	}
+
+	return true
+}
+
+// requestKey returns the api key used for a request, or nil if authentication is off
+func requestKey(c *gin.Context) *groove.APIKey {
+	if v, ok := c.Get(apiKeyContextKey); ok {
+		return v.(*groove.APIKey)
+	}
+
+	return nil
+}
+
+// requestKeyID returns the id of the api key used for a request, or an empty string if authentication is off
+func requestKeyID(c *gin.Context) string {
+	if key := requestKey(c); key != nil {
+		return key.ID
+	}
+
+	return ""
+}
+
+func hasPermission(key *groove.APIKey, perm groove.Permission) bool {
+	for _, p := range key.Permissions {
+		if p == perm || p == groove.PermissionAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inNamespace checks if a key can be used in a namespace, a key without namespaces can be used in every namespace
+func inNamespace(key *groove.APIKey, namespace string) bool {
+	if len(key.Namespaces) == 0 {
+		return true
+	}
+
+	for _, n := range key.Namespaces {
+		if n == namespace {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inScope checks if a task id or prefix is under one of the prefixes of a key
+func inScope(key *groove.APIKey, target string) bool {
+	if len(key.Prefixes) == 0 {
+		return true
+	}
+
+	for _, p := range key.Prefixes {
+		if target == p || strings.HasPrefix(target, p+".") {
+			return true
+		}
+	}
+
+	return false
+}
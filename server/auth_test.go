@@ -0,0 +1,172 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func TestGrooveMaster_Auth(t *testing.T) {
+	g := New(WithAPIKeys([]groove.APIKey{
+		{ID: "producer", Secret: "producer-secret", Prefixes: []string{"auth.a"}, Permissions: []groove.Permission{groove.PermissionEnqueue}},
+		{ID: "elsewhere", Secret: "elsewhere-secret", Prefixes: []string{"auth.b"}, Permissions: []groove.Permission{groove.PermissionEnqueue}},
+		{ID: "worker", Secret: "worker-secret", Prefixes: []string{"auth.a"}, Permissions: []groove.Permission{groove.PermissionDequeue}},
+		{ID: "other", Secret: "other-secret", Permissions: []groove.Permission{groove.PermissionDequeue}},
+		{ID: "admin", Secret: "admin-secret", Permissions: []groove.Permission{groove.PermissionAdmin}},
+	}))
+	defer g.Close()
+
+	srv := httptest.NewServer(g.Handler())
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	producer := groove.New(srv.URL, groove.WithAPIKey("producer-secret"))
+	elsewhere := groove.New(srv.URL, groove.WithAPIKey("elsewhere-secret"))
+	worker := groove.New(srv.URL, groove.WithSignedRequests("worker", "worker-secret"))
+	other := groove.New(srv.URL, groove.WithAPIKey("other-secret"))
+	admin := groove.New(srv.URL, groove.WithSignedRequests("admin", "admin-secret"))
+
+	expectError := func(err error, contains string) {
+		t.Helper()
+
+		if err == nil || !strings.Contains(err.Error(), contains) {
+			t.Errorf("expected an error containing %q, got %v", contains, err)
+		}
+	}
+
+	_, err := groove.New(srv.URL).Enqueue(ctx, []groove.Task{{ID: "auth.a.1"}}, false)
+	expectError(err, "an api key is required")
+
+	_, err = groove.New(srv.URL, groove.WithAPIKey("wrong")).Enqueue(ctx, []groove.Task{{ID: "auth.a.1"}}, false)
+	expectError(err, "invalid api key")
+
+	_, err = groove.New(srv.URL, groove.WithSignedRequests("worker", "wrong")).Stats(ctx, "auth.a", 1)
+	expectError(err, "invalid request signature")
+
+	// The body of a signed request is read before the signature is checked, so its size is limited
+	req, _ := http.NewRequest("POST", srv.URL+"/enqueue", bytes.NewReader(make([]byte, maxSignedBodyBytes+1)))
+	req.Header.Set(groove.KeyIDHeader, "worker")
+	req.Header.Set(groove.TimestampHeader, strconv.FormatInt(time.Now().Unix(), 10))
+	req.Header.Set(groove.NonceHeader, groove.NewNonce())
+	req.Header.Set(groove.RequestSignatureHeader, "unchecked")
+
+	hres, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	hres.Body.Close()
+
+	if hres.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected an oversized signed request to be rejected with 413, got %d", hres.StatusCode)
+		return
+	}
+
+	// A signed request can only be used once
+	body := []byte(`{"desired_task_count": 1, "prefix": "auth.a", "timeout": 1000}`)
+	now := time.Now()
+	nonce := groove.NewNonce()
+
+	for i, expected := range []int{http.StatusOK, http.StatusUnauthorized} {
+		req, _ = http.NewRequest("POST", srv.URL+"/dequeue", bytes.NewReader(body))
+		req.Header.Set(groove.KeyIDHeader, "worker")
+		req.Header.Set(groove.TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+		req.Header.Set(groove.NonceHeader, nonce)
+		req.Header.Set(groove.RequestSignatureHeader, groove.SignRequest("worker-secret", "POST", "/dequeue", groove.DefaultNamespace, now, nonce, body))
+
+		hres, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		hres.Body.Close()
+
+		if hres.StatusCode != expected {
+			t.Errorf("expected attempt %d of a signed request to get %d, got %d", i+1, expected, hres.StatusCode)
+			return
+		}
+	}
+
+	_, err = producer.Enqueue(ctx, []groove.Task{{ID: "auth.a.1"}, {ID: "auth.b.1"}}, false)
+	expectError(err, "cannot access auth.b.1")
+
+	_, err = producer.Enqueue(ctx, []groove.Task{{ID: "auth.a.1"}}, false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = producer.EnqueueBatch(ctx, "auth-batch", []groove.Task{{ID: "auth.a.b.1"}}, &groove.BatchCallback{Prefix: "auth.a.done"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = producer.Batch(ctx, "auth-batch")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// A batch can only be seen by keys that cover the prefixes of its tasks and callback
+	_, err = elsewhere.Batch(ctx, "auth-batch")
+	expectError(err, "cannot access auth.a")
+
+	_, err = producer.Dequeue(ctx, groove.DequeueTaskInput{DesiredTaskCount: 1, Prefix: "auth.a", Timeout: 60000})
+	expectError(err, "does not have the dequeue permission")
+
+	_, err = worker.Dequeue(ctx, groove.DequeueTaskInput{DesiredTaskCount: 1, Prefix: "auth", Timeout: 60000})
+	expectError(err, "cannot access auth")
+
+	res, err := worker.Dequeue(ctx, groove.DequeueTaskInput{DesiredTaskCount: 1, Prefix: "auth.a", Timeout: 60000})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(res.TaskSet.Tasks) != 1 {
+		t.Errorf("expected a task to be dequeued, got %+v", res.TaskSet)
+		return
+	}
+
+	// Only the key that dequeued a task set can finish it
+	_, err = other.Ack(ctx, groove.AckInput{TaskSetID: res.TaskSet.ID})
+	expectError(err, "was dequeued by another api key")
+
+	_, err = other.Extend(ctx, res.TaskSet.ID, time.Minute)
+	expectError(err, "was dequeued by another api key")
+
+	_, err = worker.Ack(ctx, groove.AckInput{TaskSetID: res.TaskSet.ID, Enqueue: []groove.Task{{ID: "auth.a.2"}}})
+	expectError(err, "does not have the enqueue permission")
+
+	_, err = worker.Ack(ctx, groove.AckInput{TaskSetID: res.TaskSet.ID})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = worker.Pause(ctx, "auth.a")
+	expectError(err, "does not have the admin permission")
+
+	_, err = admin.Pause(ctx, "auth.a")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = admin.Stats(ctx, "", 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// batchRetention is how long a completed batch can still be looked up before it is removed
+const batchRetention = 10 * time.Minute
+
+// batchLog keeps track of a batch, along with the results of every finished task in it
+type batchLog struct {
+	groove.Batch
+
+	results map[string]interface{}
+	errors  map[string][]interface{}
+
+	prefixes map[string]bool // The prefixes of the tasks in the batch and of its callback, an api key must cover them to see the batch
+}
+
+// EnqueueBatch enqueues tasks as part of a batch. A batch can be added to by multiple calls
+// until every task in it has finished, at which point the batch is complete and the callback (if any) is enqueued
+func (g *GrooveMaster) EnqueueBatch(batchID string, callback *groove.BatchCallback, tasks []groove.Task) error {
+	if batchID == "" {
+		return errors.New("batch id is required")
+	}
+
+	if callback != nil && callback.Prefix == "" {
+		return errors.New("batch callback requires a prefix")
+	}
+
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	b, ok := g.batches[batchID]
+	if ok && b.Complete {
+		return errors.New("batch has already completed")
+	}
+
+	tasks, err := g.admit(tasks)
+	if err != nil {
+		return err
+	}
+
+	// The batch is only made once the tasks are admitted, a batch that is never given any tasks would never complete
+	if !ok {
+		b = &batchLog{
+			Batch: groove.Batch{
+				ID:        batchID,
+				CreatedAt: g.clock.Now(),
+			},
+			results:  map[string]interface{}{},
+			errors:   map[string][]interface{}{},
+			prefixes: map[string]bool{},
+		}
+
+		g.batches[batchID] = b
+	}
+
+	if callback != nil {
+		b.Callback = callback
+		b.prefixes[callback.Prefix] = true
+	}
+
+	for _, t := range tasks {
+		t.BatchID = batchID
+
+		if g.putTask(t) {
+			b.Pending++
+			b.prefixes[taskPrefix(t.ID)] = true
+		}
+	}
+
+	// A batch with nothing in it completes straight away
+	if b.Pending == 0 {
+		g.completeBatch(b)
+	}
+
+	return nil
+}
+
+// Batch returns the current progress of a batch
+func (g *GrooveMaster) Batch(batchID string) (groove.Batch, bool) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	b, ok := g.batches[batchID]
+	if !ok {
+		return groove.Batch{}, false
+	}
+
+	return b.Batch, true
+}
+
+// batchPrefixes lists the prefixes of the tasks in a batch and of its callback, sorted
+func (g *GrooveMaster) batchPrefixes(batchID string) []string {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	b, ok := g.batches[batchID]
+	if !ok {
+		return nil
+	}
+
+	return b.sortedPrefixes()
+}
+
+func (b *batchLog) sortedPrefixes() []string {
+	prefixes := make([]string, 0, len(b.prefixes))
+
+	for p := range b.prefixes {
+		prefixes = append(prefixes, p)
+	}
+
+	sort.Strings(prefixes)
+
+	return prefixes
+}
+
+// finishBatchTask is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) finishBatchTask(task groove.Task) {
+	b, ok := g.batches[task.BatchID]
+	if !ok || b.Complete {
+		return
+	}
+
+	b.Pending--
+
+	if task.Succeeded {
+		b.Succeeded++
+
+		if task.Result != nil {
+			b.results[task.ID] = task.Result
+		}
+	} else {
+		b.Failed++
+
+		if len(task.Errors) > 0 {
+			b.errors[task.ID] = task.Errors
+		}
+	}
+
+	if b.Pending <= 0 {
+		g.completeBatch(b)
+	}
+}
+
+// completeBatch is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) completeBatch(b *batchLog) {
+	now := g.clock.Now()
+
+	b.Pending = 0
+	b.Complete = true
+	b.CompletedAt = &now
+
+	if b.Callback != nil {
+		g.putTask(groove.Task{
+			ID: b.Callback.Prefix + "." + b.ID,
+			Data: groove.BatchResult{
+				BatchID:   b.ID,
+				Succeeded: b.Succeeded,
+				Failed:    b.Failed,
+				Results:   b.results,
+				Errors:    b.errors,
+				Data:      b.Callback.Data,
+			},
+			RetryThreshold: b.Callback.RetryThreshold,
+		})
+	}
+
+	// Results are only needed for the callback
+	b.results = nil
+	b.errors = nil
+}
+
+// pruneBatches removes completed batches that are older than batchRetention
+// pruneBatches is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) pruneBatches() {
+	now := g.clock.Now()
+
+	for id, b := range g.batches {
+		if b.Complete && now.Sub(*b.CompletedAt) > batchRetention {
+			delete(g.batches, id)
+		}
+	}
+}
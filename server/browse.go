@@ -0,0 +1,165 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+var (
+	// errInvalidCursor is returned when a browse cursor could not be decoded
+	errInvalidCursor = errors.New("invalid cursor")
+
+	// errPrefixNotFound is returned when there is no container for a prefix
+	errPrefixNotFound = errors.New("prefix did not exist")
+)
+
+// BrowsePrefixes lists the children of the container at prefix in key order, starting after the cursor.
+// The returned cursor is empty when there are no more children
+func (g *GrooveMaster) BrowsePrefixes(prefix string, cursor string, limit int) (*groove.BrowsePrefixesResponse, error) {
+	after := ""
+
+	if cursor != "" {
+		b, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, errInvalidCursor
+		}
+
+		after = string(b)
+	}
+
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	tc := g.RootContainer
+
+	if prefix != "" {
+		tc, _ = g.RootContainer.GetChildContainer(prefix)
+		if tc == nil {
+			return nil, errPrefixNotFound
+		}
+	}
+
+	var keys []string
+
+	for k := range tc.Children {
+		if cursor == "" || k > after {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	res := &groove.BrowsePrefixesResponse{
+		Status:   "ok",
+		Prefixes: []groove.PrefixStats{},
+	}
+
+	if len(keys) > limit {
+		keys = keys[:limit]
+		res.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(keys[limit-1]))
+	}
+
+	now := g.clock.Now()
+
+	for _, k := range keys {
+		childPath := k
+		if prefix != "" {
+			childPath = prefix + "." + k
+		}
+
+		res.Prefixes = append(res.Prefixes, tc.Children[k].stats(childPath, now))
+	}
+
+	return res, nil
+}
+
+// BrowseTasks lists the tasks in the container at prefix, starting with the locked task (if any) and followed by
+// pending tasks in the order they will be dequeued. Pending tasks are paginated, starting after the cursor.
+// When redact is true task data is not included
+func (g *GrooveMaster) BrowseTasks(prefix string, cursor string, limit int, redact bool) (*groove.BrowseTasksResponse, error) {
+	var after uint64
+
+	if cursor != "" {
+		var err error
+
+		after, err = decodeTaskCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	tc, _ := g.RootContainer.GetChildContainer(prefix)
+	if tc == nil || prefix == "" {
+		return nil, errPrefixNotFound
+	}
+
+	res := &groove.BrowseTasksResponse{
+		Status: "ok",
+		Tasks:  []groove.Task{},
+	}
+
+	if tc.Locked && cursor == "" {
+		locked := *tc.LockedTask
+		res.LockedTask = &locked
+		res.LockedBy = tc.LockedBy
+	}
+
+	start := 0
+
+	if cursor != "" {
+		// Pending tasks are ordered by when they were enqueued, so the cursor can be found without a scan.
+		// A task that was requeued goes back to the front with the Seq it already had, so the order holds
+		start = sort.Search(len(tc.Tasks), func(i int) bool {
+			return tc.Tasks[i].Seq > after
+		})
+	}
+
+	end := start + limit
+	if end > len(tc.Tasks) {
+		end = len(tc.Tasks)
+	}
+
+	res.Tasks = append(res.Tasks, tc.Tasks[start:end]...)
+
+	if end < len(tc.Tasks) && len(res.Tasks) > 0 {
+		last := res.Tasks[len(res.Tasks)-1]
+		res.NextCursor = encodeTaskCursor(last.Seq)
+	}
+
+	if redact {
+		for i := range res.Tasks {
+			res.Tasks[i].Data = nil
+		}
+
+		if res.LockedTask != nil {
+			res.LockedTask.Data = nil
+		}
+	}
+
+	return res, nil
+}
+
+func encodeTaskCursor(seq uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(seq, 10)))
+}
+
+func decodeTaskCursor(cursor string) (uint64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errInvalidCursor
+	}
+
+	seq, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		return 0, errInvalidCursor
+	}
+
+	return seq, nil
+}
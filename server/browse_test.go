@@ -0,0 +1,120 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func TestGrooveMaster_Browse(t *testing.T) {
+	g := New()
+
+	var tasks []groove.Task
+
+	for i := 0; i < 25; i++ {
+		tasks = append(tasks, groove.Task{ID: fmt.Sprintf("browse.queue.%d", i), Data: "secret"})
+		tasks = append(tasks, groove.Task{ID: fmt.Sprintf("browse.p%02d.task", i)})
+	}
+
+	g.Enqueue(tasks)
+
+	var prefixes []string
+	cursor := ""
+
+	for {
+		res, err := g.BrowsePrefixes("browse", cursor, 10)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		for _, p := range res.Prefixes {
+			prefixes = append(prefixes, p.Prefix)
+		}
+
+		if res.NextCursor == "" {
+			break
+		}
+
+		cursor = res.NextCursor
+	}
+
+	if len(prefixes) != 26 || prefixes[0] != "browse.p00" || prefixes[25] != "browse.queue" {
+		t.Errorf("unexpected prefixes %v", prefixes)
+	}
+
+	g.Dequeue(1, "browse.queue", 10*time.Second)
+
+	var ids []string
+	cursor = ""
+
+	for page := 0; ; page++ {
+		res, err := g.BrowseTasks("browse.queue", cursor, 10, true)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if page == 0 && (res.LockedTask == nil || res.LockedTask.ID != "browse.queue.0" || res.LockedTask.Data != nil) {
+			t.Errorf("expected the first page to include the redacted locked task, got %+v", res.LockedTask)
+		}
+
+		for _, task := range res.Tasks {
+			if task.Data != nil {
+				t.Error("expected task data to be redacted")
+			}
+
+			ids = append(ids, task.ID)
+		}
+
+		if res.NextCursor == "" {
+			break
+		}
+
+		cursor = res.NextCursor
+	}
+
+	if len(ids) != 24 || ids[0] != "browse.queue.1" || ids[23] != "browse.queue.24" {
+		t.Errorf("unexpected task ids %v", ids)
+	}
+
+	if _, err := g.BrowseTasks("browse.missing", "", 10, false); err != errPrefixNotFound {
+		t.Errorf("expected a missing prefix error, got %v", err)
+	}
+}
+
+func TestGrooveMaster_BrowseSameEnqueueTime(t *testing.T) {
+	g := New()
+
+	for i := 0; i < 5; i++ {
+		g.Enqueue([]groove.Task{{ID: fmt.Sprintf("browse.same.%d", i)}})
+	}
+
+	tc, _ := g.RootContainer.GetChildContainer("browse.same")
+
+	// Tasks enqueued close together can share an enqueue time
+	for i := range tc.Tasks {
+		tc.Tasks[i].EnqueuedAt = tc.Tasks[0].EnqueuedAt
+	}
+
+	res, err := g.BrowseTasks("browse.same", "", 2, false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// The cursor points at browse.same.1, which is gone by the time the next page is read
+	tc.Tasks = append(tc.Tasks[:1:1], tc.Tasks[2:]...)
+
+	res, err = g.BrowseTasks("browse.same", res.NextCursor, 2, false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(res.Tasks) != 2 || res.Tasks[0].ID != "browse.same.2" || res.Tasks[1].ID != "browse.same.3" {
+		t.Errorf("expected the second page to carry on from browse.same.2, got %+v", res.Tasks)
+	}
+}
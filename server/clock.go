@@ -0,0 +1,40 @@
+package server
+
+import "time"
+
+// Clock tells the GrooveMaster what time it is and when to wake up, it can be replaced to control time in tests
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a time.Timer made by a Clock, C is a method so that fake clocks can implement it.
+// ResetAt is Reset with a deadline, so that the clock moving on before it is called does not push the deadline back
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+	ResetAt(deadline time.Time) bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+func (t realTimer) ResetAt(deadline time.Time) bool {
+	return t.Timer.Reset(time.Until(deadline))
+}
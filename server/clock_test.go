@@ -0,0 +1,250 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// testClock is a clock that only moves when the test advances it, its timers fire as it passes them
+type testClock struct {
+	mx     sync.Mutex
+	now    time.Time
+	timers []*testTimer
+}
+
+func (c *testClock) Now() time.Time {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	return c.now
+}
+
+func (c *testClock) NewTimer(d time.Duration) Timer {
+	t := &testTimer{clock: c, c: make(chan time.Time, 1)}
+
+	c.mx.Lock()
+	c.timers = append(c.timers, t)
+	c.mx.Unlock()
+
+	t.Reset(d)
+
+	return t
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.now = c.now.Add(d)
+
+	for _, t := range c.timers {
+		if t.active && !t.at.After(c.now) {
+			t.fire()
+		}
+	}
+}
+
+type testTimer struct {
+	clock  *testClock
+	c      chan time.Time
+	at     time.Time
+	active bool
+}
+
+func (t *testTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *testTimer) Stop() bool {
+	t.clock.mx.Lock()
+	defer t.clock.mx.Unlock()
+
+	active := t.active
+	t.active = false
+
+	return active
+}
+
+func (t *testTimer) Reset(d time.Duration) bool {
+	t.clock.mx.Lock()
+	deadline := t.clock.now.Add(d)
+	t.clock.mx.Unlock()
+
+	return t.ResetAt(deadline)
+}
+
+func (t *testTimer) ResetAt(deadline time.Time) bool {
+	t.clock.mx.Lock()
+	defer t.clock.mx.Unlock()
+
+	active := t.active
+	t.active = true
+	t.at = deadline
+
+	if !deadline.After(t.clock.now) {
+		t.fire()
+	}
+
+	return active
+}
+
+// fire is not safe to be called on it's own. The caller must hold the clock's lock
+func (t *testTimer) fire() {
+	t.active = false
+
+	select {
+	case t.c <- t.clock.now:
+	default:
+	}
+}
+
+// waitForEvents waits until the GrooveMaster has emitted n events of a type, failing the test if it takes too long.
+// Only the wait is in real time, the events themselves are driven by the test clock
+func waitForEvents(t *testing.T, g *GrooveMaster, typ groove.EventType, n int) bool {
+	t.Helper()
+
+	deadline := time.After(5 * time.Second)
+
+	for {
+		events, _, wait := g.Events(0)
+
+		count := 0
+		for _, e := range events {
+			if e.Type == typ {
+				count++
+			}
+		}
+
+		if count >= n {
+			return true
+		}
+
+		select {
+		case <-wait:
+		case <-deadline:
+			t.Errorf("expected %d %s events, got %d", n, typ, count)
+			return false
+		}
+	}
+}
+
+func TestGrooveMaster_TimeoutNack(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+
+	g := New(WithClock(clock))
+	defer g.Close()
+
+	g.Enqueue([]groove.Task{{ID: "clock.a.1", RetryThreshold: 1}})
+
+	ts := g.Dequeue(1, "clock", 30*time.Second)
+	if ts == nil {
+		t.Error("expected a task set to be dequeued")
+		return
+	}
+
+	clock.advance(29 * time.Second)
+
+	if g.Dequeue(1, "clock", time.Minute) != nil {
+		t.Error("expected the task to stay locked before its timeout")
+		return
+	}
+
+	clock.advance(2 * time.Second)
+
+	if !waitForEvents(t, g, groove.EventTimedOut, 1) {
+		return
+	}
+
+	g.mx.Lock()
+	_, inFlight := g.TaskSetLogs[ts.ID]
+	leases := g.leases.Len()
+	g.mx.Unlock()
+
+	if inFlight || leases != 0 {
+		t.Errorf("expected the timed out task set to be removed, got %d leases", leases)
+		return
+	}
+
+	retry := g.Dequeue(1, "clock", 30*time.Second)
+	if retry == nil || len(retry.Tasks) != 1 {
+		t.Error("expected the timed out task to be dequeued again")
+		return
+	}
+
+	if task := retry.Tasks[0]; task.RetryCount != 1 || len(task.Errors) != 1 {
+		t.Errorf("expected the task to have been nacked once, got %d retries and %d errors", task.RetryCount, len(task.Errors))
+	}
+}
+
+func TestGrooveMaster_TimeoutRetryExhaustion(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+
+	g := New(WithClock(clock))
+	defer g.Close()
+
+	g.Enqueue([]groove.Task{{ID: "clock.b.1", RetryThreshold: 2}})
+
+	// The first attempt and both retries time out
+	for i := 1; i <= 3; i++ {
+		if g.Dequeue(1, "clock", 10*time.Second) == nil {
+			t.Errorf("expected attempt %d to be dequeued", i)
+			return
+		}
+
+		clock.advance(11 * time.Second)
+
+		if !waitForEvents(t, g, groove.EventTimedOut, i) {
+			return
+		}
+	}
+
+	if !waitForEvents(t, g, groove.EventDeadLettered, 1) {
+		return
+	}
+
+	if g.Dequeue(1, "clock", 10*time.Second) != nil {
+		t.Error("expected no more attempts once retries are exhausted")
+		return
+	}
+
+	letters := g.DeadLetters("clock", 10)
+	if len(letters) != 1 || letters[0].Task.RetryCount != 3 || !letters[0].DeadAt.Equal(clock.Now()) {
+		t.Errorf("expected the task to be dead lettered at the current time after 3 attempts, got %+v", letters)
+	}
+}
+
+func TestGrooveMaster_HousekeepingClock(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+
+	g := New(WithClock(clock))
+	defer g.Close()
+
+	// A batch with nothing in it completes straight away
+	if err := g.EnqueueBatch("clock", nil, nil); err != nil {
+		t.Error(err)
+		return
+	}
+
+	clock.advance(batchRetention + time.Second)
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		_, found := g.Batch("clock")
+		h := g.Health()
+
+		if !found && h.Alive && h.LastTick.Equal(clock.Now()) {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Errorf("expected the batch to be pruned and the tick recorded by the clock, got found %t and %+v", found, h)
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,280 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// ErrPayloadTooLarge is returned (wrapped) when the data of a task is larger than its limit
+var ErrPayloadTooLarge = errors.New("task payload too large")
+
+// Defaults are used by tasks and dequeues that leave a setting as zero
+type Defaults struct {
+	DequeueTimeout groove.Duration `json:"dequeue_timeout"` // How long a task set is locked for when the dequeue does not say
+	RetryThreshold int             `json:"retry_threshold"` // How many times a task is retried when it does not say
+}
+
+// Config holds every setting of a groove server
+type Config struct {
+	ListenAddress   string          `json:"listen_address"`
+	ShutdownTimeout groove.Duration `json:"shutdown_timeout"` // How long a shutdown waits for dequeued task sets to finish
+	TLS             TLSOptions      `json:"tls"`
+
+	APIKeysFile    string `json:"api_keys_file,omitempty"`   // A json array of api keys, authentication is off without it
+	NamespacesFile string `json:"namespaces_file,omitempty"` // A json object of namespace names to their quotas
+
+	SnapshotPath     string          `json:"snapshot_path,omitempty"` // The queue is only kept in memory without it
+	SnapshotInterval groove.Duration `json:"snapshot_interval"`
+
+	ScanInterval groove.Duration `json:"scan_interval"` // How often batches are pruned and snapshots are considered
+
+	LogLevel           string `json:"log_level"`            // debug, info, warn or error
+	LogSampleRate      int    `json:"log_sample_rate"`      // Only one in this many high volume logs are written
+	MetricsPrefixDepth int    `json:"metrics_prefix_depth"` // How many parts of a task id break down queue depth, unless /metrics says
+
+	Limits   Limits                   `json:"limits"`
+	Defaults Defaults                 `json:"defaults"`
+	Policies map[string]groove.Policy `json:"policies,omitempty"` // Keyed by prefix
+}
+
+// DefaultConfig returns the settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		ListenAddress:      "0.0.0.0:9854",
+		ShutdownTimeout:    groove.Duration(30 * time.Second),
+		SnapshotInterval:   groove.Duration(10 * time.Second),
+		ScanInterval:       groove.Duration(100 * time.Millisecond),
+		LogLevel:           "info",
+		LogSampleRate:      1,
+		MetricsPrefixDepth: 1,
+		Limits:             DefaultLimits,
+		Defaults: Defaults{
+			DequeueTimeout: groove.Duration(30 * time.Second),
+		},
+	}
+}
+
+// configEnv lists the environment variables that override the config file, in the order they are applied
+var configEnv = []struct {
+	key string
+	set func(c *Config, v string) error
+}{
+	{"LISTEN_ADDRESS", func(c *Config, v string) error { c.ListenAddress = v; return nil }},
+	// PORT only replaces the port, so it is applied after LISTEN_ADDRESS
+	{"PORT", func(c *Config, v string) error {
+		host, _, err := net.SplitHostPort(c.ListenAddress)
+		if err != nil {
+			return err
+		}
+
+		c.ListenAddress = net.JoinHostPort(host, v)
+		return nil
+	}},
+	{"SHUTDOWN_TIMEOUT", durationEnv(func(c *Config) *groove.Duration { return &c.ShutdownTimeout })},
+	{"TLS_CERT_FILE", func(c *Config, v string) error { c.TLS.CertFile = v; return nil }},
+	{"TLS_KEY_FILE", func(c *Config, v string) error { c.TLS.KeyFile = v; return nil }},
+	{"TLS_CLIENT_CA_FILE", func(c *Config, v string) error { c.TLS.ClientCAFile = v; return nil }},
+	{"API_KEYS_FILE", func(c *Config, v string) error { c.APIKeysFile = v; return nil }},
+	{"NAMESPACES_FILE", func(c *Config, v string) error { c.NamespacesFile = v; return nil }},
+	{"SNAPSHOT_PATH", func(c *Config, v string) error { c.SnapshotPath = v; return nil }},
+	{"SNAPSHOT_INTERVAL", durationEnv(func(c *Config) *groove.Duration { return &c.SnapshotInterval })},
+	{"SCAN_INTERVAL", durationEnv(func(c *Config) *groove.Duration { return &c.ScanInterval })},
+	{"LOG_LEVEL", func(c *Config, v string) error { c.LogLevel = v; return nil }},
+	{"LOG_SAMPLE_RATE", intEnv(func(c *Config) *int { return &c.LogSampleRate })},
+	{"METRICS_PREFIX_DEPTH", intEnv(func(c *Config) *int { return &c.MetricsPrefixDepth })},
+	{"MAX_ENQUEUE_TASKS", intEnv(func(c *Config) *int { return &c.Limits.MaxEnqueueTasks })},
+	{"MAX_DEQUEUE_TASKS", intEnv(func(c *Config) *int { return &c.Limits.MaxDequeueTasks })},
+	{"MAX_DEAD_LETTERS", intEnv(func(c *Config) *int { return &c.Limits.MaxDeadLetters })},
+	{"DEFAULT_RETRY_THRESHOLD", intEnv(func(c *Config) *int { return &c.Defaults.RetryThreshold })},
+	{"DEFAULT_DEQUEUE_TIMEOUT", durationEnv(func(c *Config) *groove.Duration { return &c.Defaults.DequeueTimeout })},
+	{"MAX_TASK_PAYLOAD_BYTES", func(c *Config, v string) error {
+		n, err := strconv.ParseInt(v, 10, 64)
+		c.Limits.MaxTaskPayloadBytes = n
+		return err
+	}},
+}
+
+func durationEnv(field func(c *Config) *groove.Duration) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		*field(c) = groove.Duration(d)
+		return err
+	}
+}
+
+func intEnv(field func(c *Config) *int) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		*field(c) = n
+		return err
+	}
+}
+
+// LoadConfig reads the settings of a groove server. The defaults are replaced by whatever the json file at
+// path sets (if path is not empty), then by any of the environment variables that getenv returns.
+// The result is validated before it is returned
+func LoadConfig(path string, getenv func(key string) string) (Config, error) {
+	cfg := DefaultConfig()
+
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+
+		if err := dec.Decode(&cfg); err != nil {
+			return cfg, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+
+	for _, env := range configEnv {
+		if v := getenv(env.key); v != "" {
+			if err := env.set(&cfg, v); err != nil {
+				return cfg, fmt.Errorf("invalid %s: %w", env.key, err)
+			}
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate checks that the settings can be used, reporting every problem at once
+func (c Config) Validate() error {
+	var errs []error
+
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	_, _, err := net.SplitHostPort(c.ListenAddress)
+	check(err == nil, "listen_address %q must be a host and port", c.ListenAddress)
+
+	check(c.ShutdownTimeout >= 0, "shutdown_timeout cannot be negative")
+	check((c.TLS.CertFile == "") == (c.TLS.KeyFile == ""), "tls needs both a cert_file and a key_file")
+	check(c.TLS.ClientCAFile == "" || c.TLS.CertFile != "", "tls client_ca_file needs a cert_file and key_file")
+	check(c.SnapshotPath == "" || c.SnapshotInterval > 0, "snapshot_interval must be greater than 0")
+	check(c.ScanInterval > 0, "scan_interval must be greater than 0")
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.LogLevel)) == nil, "log_level %q must be debug, info, warn or error", c.LogLevel)
+	check(c.LogSampleRate > 0, "log_sample_rate must be greater than 0")
+	check(c.MetricsPrefixDepth >= 0, "metrics_prefix_depth cannot be negative")
+
+	check(c.Limits.MaxEnqueueTasks > 0, "limits.max_enqueue_tasks must be greater than 0")
+	check(c.Limits.MaxDequeueTasks > 0, "limits.max_dequeue_tasks must be greater than 0")
+	check(c.Limits.MaxDeadLetters > 0, "limits.max_dead_letters must be greater than 0")
+	check(c.Limits.MaxTaskPayloadBytes >= 0, "limits.max_task_payload_bytes cannot be negative")
+
+	check(c.Defaults.DequeueTimeout > 0, "defaults.dequeue_timeout must be greater than 0")
+	check(c.Defaults.RetryThreshold >= 0, "defaults.retry_threshold cannot be negative")
+
+	for prefix, p := range c.Policies {
+		if err := validatePolicy(prefix, p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validPrefix checks that a prefix is a dot separated list of non empty parts
+func validPrefix(prefix string) bool {
+	if prefix == "" {
+		return false
+	}
+
+	for _, part := range strings.Split(prefix, ".") {
+		if part == "" || strings.ContainsAny(part, " \t\n") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WithConfig applies the settings of a config that the GrooveMaster uses itself, and keeps the rest to be reported by Config.
+// The policies of the config are where the policies start from, they can then be changed with SetPolicy and RemovePolicy.
+// The config should be validated first
+func WithConfig(cfg Config) Option {
+	return func(g *GrooveMaster) {
+		g.config = cfg
+		g.limits = cfg.Limits
+		g.defaults = cfg.Defaults
+		g.scanInterval = time.Duration(cfg.ScanInterval)
+		g.policies = newPolicyTree(cfg.Policies)
+		g.policyChanges = map[string]*groove.Policy{}
+	}
+}
+
+// Config returns the settings that the GrooveMaster is using
+func (g *GrooveMaster) Config() Config {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	cfg := g.config
+	cfg.Limits = g.limits
+	cfg.Defaults = g.defaults
+	cfg.ScanInterval = groove.Duration(g.scanInterval)
+	cfg.Policies = g.policies.all()
+
+	return cfg
+}
+
+func (g *GrooveMaster) hConfig(c *gin.Context) {
+	if !g.authorize(c, groove.PermissionAdmin, "") {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "config": g.Config()})
+}
+
+// admit prepares tasks to be enqueued, filling in their settings from their policies. An error is returned if any
+// task is too large or the tasks would exceed the quota, in which case none of them should be enqueued.
+// admit is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) admit(tasks []groove.Task) ([]groove.Task, error) {
+	admitted := make([]groove.Task, len(tasks))
+
+	for i, t := range tasks {
+		p := g.policyFor(t.ID)
+
+		// Only EnqueueBatch puts tasks in a batch, so a client cannot finish tasks of someone else's batch
+		t.BatchID = ""
+
+		// The enqueue time is set by groove, a client cannot make a task look older than it is
+		t.EnqueuedAt = time.Time{}
+
+		if t.RetryThreshold == 0 {
+			t.RetryThreshold = *p.RetryThreshold
+		}
+
+		if limit := *p.MaxPayloadBytes; limit > 0 || g.quota.MaxPayloadBytes > 0 {
+			t.PayloadSize = payloadSize(t)
+
+			if limit > 0 && t.PayloadSize > limit {
+				return nil, fmt.Errorf("%w: task %s has %d bytes of data, the limit is %d", ErrPayloadTooLarge, t.ID, t.PayloadSize, limit)
+			}
+		}
+
+		admitted[i] = t
+	}
+
+	return admitted, g.checkEnqueueQuota(admitted)
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "groove.json")
+
+	err := os.WriteFile(path, []byte(`{
+		"listen_address": "127.0.0.1:8000",
+		"limits": {"max_enqueue_tasks": 50},
+		"defaults": {"retry_threshold": 2},
+		"policies": {"emails": {"dequeue_timeout": "5m"}}
+	}`), 0600)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	env := map[string]string{"PORT": "9000", "MAX_DEQUEUE_TASKS": "20", "LOG_LEVEL": "debug", "METRICS_PREFIX_DEPTH": "2"}
+
+	cfg, err := LoadConfig(path, func(key string) string { return env[key] })
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if cfg.ListenAddress != "127.0.0.1:9000" {
+		t.Errorf("expected PORT to replace the port of the listen address, got %s", cfg.ListenAddress)
+	}
+
+	if cfg.LogLevel != "debug" || cfg.LogSampleRate != 1 || cfg.MetricsPrefixDepth != 2 {
+		t.Errorf("expected logging and metrics settings from the env and the defaults, got %s, %d and %d", cfg.LogLevel, cfg.LogSampleRate, cfg.MetricsPrefixDepth)
+	}
+
+	if cfg.Limits.MaxEnqueueTasks != 50 || cfg.Limits.MaxDequeueTasks != 20 || cfg.Limits.MaxDeadLetters != DefaultLimits.MaxDeadLetters {
+		t.Errorf("expected limits from the file, the env and the defaults, got %+v", cfg.Limits)
+	}
+
+	if cfg.Defaults.RetryThreshold != 2 || cfg.Defaults.DequeueTimeout != groove.Duration(30*time.Second) {
+		t.Errorf("expected defaults from the file and the defaults, got %+v", cfg.Defaults)
+	}
+
+	if d := cfg.Policies["emails"].DequeueTimeout; d == nil || *d != groove.Duration(5*time.Minute) {
+		t.Errorf("expected the emails policy to be loaded, got %+v", cfg.Policies)
+	}
+
+	// PORT applies to the address from LISTEN_ADDRESS, however often it is loaded
+	env = map[string]string{"LISTEN_ADDRESS": "10.0.0.1:8000", "PORT": "9000"}
+
+	for i := 0; i < 20; i++ {
+		cfg, err = LoadConfig("", func(key string) string { return env[key] })
+		if err != nil || cfg.ListenAddress != "10.0.0.1:9000" {
+			t.Errorf("expected PORT to be applied after LISTEN_ADDRESS, got %s, %v", cfg.ListenAddress, err)
+			return
+		}
+	}
+
+	env = map[string]string{"SCAN_INTERVAL": "0s", "TLS_KEY_FILE": "key.pem", "LOG_LEVEL": "loud"}
+
+	_, err = LoadConfig("", func(key string) string { return env[key] })
+	if err == nil || !strings.Contains(err.Error(), "scan_interval") || !strings.Contains(err.Error(), "cert_file") || !strings.Contains(err.Error(), "log_level") {
+		t.Errorf("expected every invalid setting to be reported, got %v", err)
+	}
+
+	err = os.WriteFile(path, []byte(`{"listen_adress": "127.0.0.1:8000"}`), 0600)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, err = LoadConfig(path, os.Getenv); err == nil {
+		t.Error("expected a misspelt setting to be rejected")
+	}
+}
+
+func TestGrooveMaster_Policies(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Limits.MaxTaskPayloadBytes = 100
+	cfg.Defaults.RetryThreshold = 1
+	cfg.Policies = map[string]groove.Policy{
+		"policy":         {RetryThreshold: groove.Ptr(3), DequeueTimeout: groove.Ptr(groove.Duration(time.Hour))},
+		"policy.small":   {MaxPayloadBytes: groove.Ptr(int64(5))},
+		"policy.small.a": {RetryThreshold: groove.Ptr(5)},
+		"policy.large":   {MaxPayloadBytes: groove.Ptr(int64(1000)), RetryThreshold: groove.Ptr(0)},
+	}
+
+	g := New(WithConfig(cfg))
+	defer g.Close()
+
+	err := g.Enqueue([]groove.Task{{ID: "other.1"}, {ID: "policy.x.1"}, {ID: "policy.small.a.1", Data: "abc"}, {ID: "policy.y.1", RetryThreshold: 7}, {ID: "policy.large.a.1"}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	thresholds := map[string]int{}
+
+	for _, prefix := range []string{"other", "policy.x", "policy.y", "policy.small.a", "policy.large.a"} {
+		for _, task := range g.Dequeue(10, prefix, 0).Tasks {
+			thresholds[task.ID] = task.RetryThreshold
+		}
+	}
+
+	// The closest policy wins, even when it sets zero, and a threshold set on the task itself is kept
+	expected := map[string]int{"other.1": 1, "policy.x.1": 3, "policy.y.1": 7, "policy.small.a.1": 5, "policy.large.a.1": 0}
+
+	for id, threshold := range expected {
+		if thresholds[id] != threshold {
+			t.Errorf("expected %s to have a retry threshold of %d, got %d", id, threshold, thresholds[id])
+		}
+	}
+
+	for _, ts := range g.TaskSets(10) {
+		timeout := time.Until(ts.TimeoutAt)
+
+		if strings.HasPrefix(ts.TaskIDs[0], "policy") && timeout < 50*time.Minute {
+			t.Errorf("expected %s to use the policy dequeue timeout, got %s", ts.TaskIDs[0], timeout)
+		} else if strings.HasPrefix(ts.TaskIDs[0], "other") && (timeout > time.Minute || timeout < 20*time.Second) {
+			t.Errorf("expected %s to use the default dequeue timeout, got %s", ts.TaskIDs[0], timeout)
+		}
+	}
+
+	err = g.Enqueue([]groove.Task{{ID: "policy.small.b.1", Data: "too large"}})
+	if !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("expected the policy payload limit to apply, got %v", err)
+	}
+
+	err = g.Enqueue([]groove.Task{{ID: "other.2", Data: strings.Repeat("a", 100)}})
+	if !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("expected the default payload limit to apply, got %v", err)
+	}
+
+	err = g.Enqueue([]groove.Task{{ID: "policy.large.b.1", Data: strings.Repeat("a", 100)}})
+	if !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("expected a policy not to raise the server payload limit, got %v", err)
+	}
+
+	if got := g.Config(); got.Limits.MaxTaskPayloadBytes != 100 || len(got.Policies) != 4 {
+		t.Errorf("expected the config to be reported, got %+v", got)
+	}
+}
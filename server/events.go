@@ -0,0 +1,83 @@
+package server
+
+import (
+	"sync"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// eventLogSize is how many recent events are kept for /events readers
+const eventLogSize = 4096
+
+// eventLog is a fixed size ring buffer of recent events. Writers never wait on readers,
+// a reader that falls too far behind simply misses the overwritten events
+type eventLog struct {
+	mx     sync.Mutex
+	events []groove.Event
+	next   uint64        // The sequence number the next event will be given
+	notify chan struct{} // Closed and replaced every time an event is added
+}
+
+func newEventLog(size int) *eventLog {
+	return &eventLog{
+		events: make([]groove.Event, size),
+		next:   1,
+		notify: make(chan struct{}),
+	}
+}
+
+// add stores an event, assigning it the next sequence number
+func (l *eventLog) add(e groove.Event) groove.Event {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	e.Seq = l.next
+	l.events[e.Seq%uint64(len(l.events))] = e
+	l.next++
+
+	close(l.notify)
+	l.notify = make(chan struct{})
+
+	return e
+}
+
+// since returns every stored event with a sequence number of at least seq, along with the number of events
+// that were missed because they have already been overwritten. The returned channel is closed when a new event is added
+func (l *eventLog) since(seq uint64) (events []groove.Event, missed uint64, wait <-chan struct{}) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if seq == 0 {
+		seq = 1
+	}
+
+	oldest := uint64(1)
+	if l.next > uint64(len(l.events)) {
+		oldest = l.next - uint64(len(l.events))
+	}
+
+	if seq < oldest {
+		missed = oldest - seq
+		seq = oldest
+	}
+
+	for s := seq; s < l.next; s++ {
+		events = append(events, l.events[s%uint64(len(l.events))])
+	}
+
+	return events, missed, l.notify
+}
+
+// head returns the sequence number the next event will be given
+func (l *eventLog) head() uint64 {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	return l.next
+}
+
+// Events returns the recent events with a sequence number of at least seq, along with the number of events that
+// were missed because they are no longer kept. The returned channel is closed when a new event is added
+func (g *GrooveMaster) Events(seq uint64) ([]groove.Event, uint64, <-chan struct{}) {
+	return g.events.since(seq)
+}
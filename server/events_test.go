@@ -0,0 +1,39 @@
+package server
+
+import (
+	"testing"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func TestEventLog_Since(t *testing.T) {
+	l := newEventLog(4)
+
+	for i := 0; i < 6; i++ {
+		l.add(groove.Event{Type: groove.EventEnqueued})
+	}
+
+	events, missed, _ := l.since(1)
+
+	if missed != 2 {
+		t.Errorf("expected 2 missed events, got %d", missed)
+	}
+
+	if len(events) != 4 || events[0].Seq != 3 || events[3].Seq != 6 {
+		t.Errorf("unexpected events %+v", events)
+	}
+
+	events, missed, wait := l.since(l.head())
+
+	if len(events) != 0 || missed != 0 {
+		t.Error("expected no events from the head of the log")
+	}
+
+	l.add(groove.Event{Type: groove.EventAcked})
+
+	select {
+	case <-wait:
+	default:
+		t.Error("expected wait to be closed after an event was added")
+	}
+}
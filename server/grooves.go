@@ -0,0 +1,1072 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+type GrooveMaster struct {
+	mx sync.Mutex
+
+	RootContainer *TaskContainer
+	TaskSetLogs   map[string]groove.TaskSetLog
+	Waits         map[string][]chan groove.Task
+
+	leases      *leaseHeap // When each task set in TaskSetLogs times out, kept in step by putTaskSetLog and removeTaskSetLog
+	batches     map[string]*batchLog
+	deadLetters []groove.DeadLetter
+	paused      map[string]bool // Prefixes that have been paused, kept so that recreated containers stay paused
+	webhooks    *webhookDispatcher
+	events      *eventLog
+	metrics     *metrics
+	taskSeq     uint64 // The Seq of the last task that was enqueued
+
+	apiKeys          *apiKeys // Nil when authentication is off
+	clock            Clock
+	limits           Limits
+	defaults         Defaults
+	policies         *policyTree
+	policyChanges    map[string]*groove.Policy // Policies set since the config was loaded, nil where one was removed
+	config           Config                    // The settings that the GrooveMaster does not use itself, kept for Config
+	quota            groove.Quota
+	namespace        string
+	scanInterval     time.Duration // How often batches are pruned and snapshots are considered
+	storage          Storage
+	snapshotInterval time.Duration
+
+	draining bool      // Set once dequeues are stopped for a shutdown
+	lastTick time.Time // When the background loop last ran
+	lastSave time.Time // When a snapshot was last saved
+	saveErr  error     // Why the last snapshot failed to save
+
+	logger        *slog.Logger
+	sampledLogger *slog.Logger // Used for high volume logs
+	sampleRate    int
+
+	wake      chan struct{} // Signalled when the first lease to expire changes
+	stop      chan struct{} // Closed to stop the background loop
+	done      chan struct{} // Closed once the background loop has stopped
+	closeOnce sync.Once
+}
+
+// New creates a GrooveMaster and starts its background work, which runs until Close is called
+func New(opts ...Option) *GrooveMaster {
+	gm := &GrooveMaster{
+		TaskSetLogs: map[string]groove.TaskSetLog{},
+		RootContainer: &TaskContainer{
+			Children: map[string]*TaskContainer{},
+			Tasks:    nil,
+		},
+		Waits:    map[string][]chan groove.Task{},
+		leases:   newLeaseHeap(),
+		batches:  map[string]*batchLog{},
+		paused:   map[string]bool{},
+		webhooks: newWebhookDispatcher(),
+		events:   newEventLog(eventLogSize),
+		metrics:  newMetrics(),
+
+		clock:     realClock{},
+		namespace: groove.DefaultNamespace,
+
+		logger:        slog.Default(),
+		sampledLogger: slog.Default(),
+		sampleRate:    1,
+
+		wake: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	WithConfig(DefaultConfig())(gm)
+
+	for _, opt := range opts {
+		opt(gm)
+	}
+
+	gm.lastTick = gm.clock.Now()
+
+	gm.webhooks.clock = gm.clock
+	gm.webhooks.start()
+
+	// The timers are made before the loop starts, so that a clock moved straight after New still fires them
+	go gm.run(gm.clock.NewTimer(gm.scanInterval), gm.clock.NewTimer(time.Hour))
+
+	return gm
+}
+
+// Open creates a GrooveMaster like New, then restores the last snapshot from storage if WithStorage was given
+func Open(opts ...Option) (*GrooveMaster, error) {
+	gm := New(opts...)
+
+	if gm.storage == nil {
+		return gm, nil
+	}
+
+	snapshot, err := gm.storage.Load()
+	if err != nil {
+		gm.stopBackground()
+		return nil, fmt.Errorf("could not load snapshot: %w", err)
+	}
+
+	if snapshot != nil {
+		gm.Restore(snapshot)
+	}
+
+	return gm, nil
+}
+
+// run times out task sets as their leases expire, prunes finished batches and saves snapshots until the GrooveMaster is closed.
+// scan fires every scan interval for the housekeeping, timer fires when the first lease expires and is reset whenever that changes
+func (g *GrooveMaster) run(scan Timer, timer Timer) {
+	defer close(g.done)
+	defer scan.Stop()
+	defer timer.Stop()
+
+	lastSnapshot := g.clock.Now()
+
+	for {
+		housekeeping := false
+
+		select {
+		case <-g.stop:
+			return
+		case <-scan.C():
+			housekeeping = true
+			scan.Reset(g.scanInterval)
+		case <-timer.C():
+		case <-g.wake:
+		}
+
+		g.mx.Lock()
+		now := g.clock.Now()
+
+		g.expireLeases()
+
+		if housekeeping {
+			g.pruneBatches()
+		}
+
+		next, ok := g.leases.next()
+		g.mx.Unlock()
+
+		resetTimer(timer, next, ok)
+
+		if !housekeeping {
+			continue
+		}
+
+		if g.storage != nil && now.Sub(lastSnapshot) >= g.snapshotInterval {
+			lastSnapshot = now
+
+			err := g.save()
+			if err != nil {
+				g.logger.Error("failed to save snapshot", slog.String("error", err.Error()))
+			}
+		}
+
+		g.mx.Lock()
+		g.lastTick = g.clock.Now()
+		g.mx.Unlock()
+	}
+}
+
+// resetTimer makes the timer fire once the lease expiring at next has expired, or not for a long time if there is none
+func resetTimer(timer Timer, next time.Time, ok bool) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C():
+		default:
+		}
+	}
+
+	if !ok {
+		timer.Reset(time.Hour)
+		return
+	}
+
+	// Leases expire once the clock is strictly after their timeout
+	timer.ResetAt(next.Add(time.Nanosecond))
+}
+
+// Close stops the background work of the GrooveMaster and saves a final snapshot if storage was given.
+// The GrooveMaster must not be used once it is closed
+func (g *GrooveMaster) Close() error {
+	g.stopBackground()
+
+	return g.save()
+}
+
+func (g *GrooveMaster) stopBackground() {
+	g.closeOnce.Do(func() {
+		close(g.stop)
+		<-g.done
+
+		g.webhooks.stop()
+	})
+}
+
+func (g *GrooveMaster) Print() {
+	fmt.Print(g.RootContainer.String())
+}
+
+// Enqueue adds tasks to the queue, no tasks are added if they would exceed the quota
+func (g *GrooveMaster) Enqueue(tasks []groove.Task) error {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	tasks, err := g.admit(tasks)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tasks {
+		g.putTask(t)
+	}
+
+	return nil
+}
+
+func (g *GrooveMaster) EnqueueAndWait(tasks []groove.Task) ([]chan groove.Task, error) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	// Nothing is dequeued once draining, so the tasks would never finish
+	if g.draining {
+		return nil, ErrDraining
+	}
+
+	tasks, err := g.admit(tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	var waits []chan groove.Task
+
+	for _, t := range tasks {
+		g.putTask(t)
+		waits = append(waits, g.putWait(t.ID))
+	}
+
+	return waits, nil
+}
+
+// Ack is used to acknowledge that all work in a TaskSet has been completed
+func (g *GrooveMaster) Ack(taskSetID string, result interface{}) error {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	return g.ack(taskSetID, result)
+}
+
+// AckAndEnqueue acknowledges a TaskSet and enqueues follow up tasks as a single operation.
+// The tasks are only enqueued if the ack succeeds
+func (g *GrooveMaster) AckAndEnqueue(taskSetID string, result interface{}, tasks []groove.Task) error {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	tasks, err := g.admit(tasks)
+	if err != nil {
+		return err
+	}
+
+	err = g.ack(taskSetID, result)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tasks {
+		g.putTask(t)
+	}
+
+	return nil
+}
+
+// ack is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) ack(taskSetID string, result interface{}) error {
+	// Load the task set log
+	ts, ok := g.TaskSetLogs[taskSetID]
+	if ok {
+		err := g.checkLocked(ts.TaskIDs)
+		if err != nil {
+			return err
+		}
+
+		// Update task containers for each task
+		for _, taskID := range ts.TaskIDs {
+			idParts := strings.Split(taskID, ".")
+
+			// Find the TaskContainer that contains the current task
+			cc, _ := g.RootContainer.GetChildContainer(strings.Join(idParts[:len(idParts)-1], "."))
+			if cc != nil {
+				if cc.Locked && cc.LockedTask.ID == taskID {
+					cc.LockedTask.Result = result
+					cc.LockedTask.Succeeded = true
+
+					g.emit(groove.EventAcked, taskSetID, *cc.LockedTask)
+					g.finishTask(*cc.LockedTask)
+
+					cc.unlock(false, g.clock.Now())
+					cc.prune()
+				} else {
+					return errors.New("task set was not locked")
+				}
+			}
+		}
+
+		// Remove task set log
+		g.removeTaskSetLog(taskSetID)
+	} else {
+		return errors.New("task set did not exist")
+	}
+
+	return nil
+}
+
+// Nack is used to acknowledge that all work in a TaskSet has failed
+func (g *GrooveMaster) Nack(taskSetID string, errorData interface{}) error {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	return g.nack(taskSetID, errorData)
+}
+
+// Timeout nacks a TaskSet as if it had exceeded its timeout
+func (g *GrooveMaster) Timeout(taskSetID string) error {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	return g.timeout(taskSetID)
+}
+
+// timeout is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) timeout(taskSetID string) error {
+	ts, ok := g.TaskSetLogs[taskSetID]
+	if !ok {
+		return errors.New("task set did not exist")
+	}
+
+	var tasks []groove.Task
+
+	for _, taskID := range ts.TaskIDs {
+		cc := g.lockedContainer(taskID)
+		if cc != nil {
+			tasks = append(tasks, *cc.LockedTask)
+			g.emit(groove.EventTimedOut, taskSetID, *cc.LockedTask)
+		}
+	}
+
+	_, span := tracer.Start(context.Background(), "GrooveMaster.Timeout", taskLinks(tasks),
+		trace.WithAttributes(attribute.String("groove.task_set_id", taskSetID)))
+
+	err := g.nack(taskSetID, map[string]string{
+		"error": "task failed due to exceeding timeout",
+	})
+
+	endSpan(span, err)
+
+	return err
+}
+
+// nack is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) nack(taskSetID string, errorData interface{}) error {
+	// Load the task set log
+	ts, ok := g.TaskSetLogs[taskSetID]
+	if ok {
+		err := g.checkLocked(ts.TaskIDs)
+		if err != nil {
+			return err
+		}
+
+		// Update task containers for each task
+		for _, taskID := range ts.TaskIDs {
+			idParts := strings.Split(taskID, ".")
+
+			// Find the TaskContainer that contains the current task
+			cc, _ := g.RootContainer.GetChildContainer(strings.Join(idParts[:len(idParts)-1], "."))
+			if cc != nil {
+				if cc.Locked && cc.LockedTask.ID == taskID {
+					cc.LockedTask.RetryCount++
+					cc.LockedTask.Errors = append(cc.LockedTask.Errors, errorData)
+
+					g.emit(groove.EventNacked, taskSetID, *cc.LockedTask)
+
+					// Kill the task
+					if cc.LockedTask.RetryCount > cc.LockedTask.RetryThreshold {
+
+						cc.LockedTask.Succeeded = false
+
+						g.deadLetter(taskSetID, *cc.LockedTask)
+
+						g.finishTask(*cc.LockedTask)
+
+						cc.unlock(false, g.clock.Now())
+						cc.prune()
+
+					} else {
+						// Place the task back on the queue
+						cc.unlock(true, g.clock.Now())
+					}
+				} else {
+					return errors.New("task set was not locked")
+				}
+			}
+		}
+
+		// Remove task set log
+		g.removeTaskSetLog(taskSetID)
+	} else {
+		return errors.New("task set did not exist")
+	}
+
+	return nil
+}
+
+// NackTask is used to note that a single task in a task set has failed
+func (g *GrooveMaster) NackTask(taskSetID string, failedTaskID string, errorData interface{}) error {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	return g.nackTask(taskSetID, failedTaskID, errorData)
+}
+
+// nackTask is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) nackTask(taskSetID string, failedTaskID string, errorData interface{}) error {
+	// Load the task set log
+	ts, ok := g.TaskSetLogs[taskSetID]
+	if ok {
+
+		nacked := false
+
+		// Update task containers for each task
+		for i, taskID := range ts.TaskIDs {
+			if taskID == failedTaskID {
+				idParts := strings.Split(taskID, ".")
+
+				// Find the TaskContainer that contains the current task
+				cc, _ := g.RootContainer.GetChildContainer(strings.Join(idParts[:len(idParts)-1], "."))
+				if cc != nil {
+					if cc.Locked && cc.LockedTask.ID == taskID {
+						cc.LockedTask.RetryCount++
+
+						if errorData != nil {
+							cc.LockedTask.Errors = append(cc.LockedTask.Errors, errorData)
+						}
+
+						g.emit(groove.EventNacked, taskSetID, *cc.LockedTask)
+
+						// Kill the task
+						if cc.LockedTask.RetryCount > cc.LockedTask.RetryThreshold {
+							cc.LockedTask.Succeeded = false
+
+							g.deadLetter(taskSetID, *cc.LockedTask)
+
+							g.finishTask(*cc.LockedTask)
+
+							cc.unlock(false, g.clock.Now())
+							cc.prune()
+
+						} else {
+							// Add task back to front of list
+							cc.unlock(true, g.clock.Now())
+						}
+
+						// Remove task from TaskSet
+						ts.TaskIDs = append(ts.TaskIDs[:i], ts.TaskIDs[i+1:]...)
+
+					} else {
+						return errors.New("task set was not locked")
+					}
+				}
+
+				nacked = true
+
+				break
+			}
+		}
+
+		if !nacked {
+			return errors.New("task did not exist")
+		}
+
+		// Remove the task set if there are no more tasks
+		if len(ts.TaskIDs) == 0 {
+			g.removeTaskSetLog(taskSetID)
+		} else {
+			g.putTaskSetLog(ts)
+		}
+	} else {
+		return errors.New("task set did not exist")
+	}
+
+	return nil
+}
+
+// AckTask is used to note that a single task in a task set has been completed
+func (g *GrooveMaster) AckTask(taskSetID string, succeededTaskID string, result interface{}) error {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	return g.ackTask(taskSetID, succeededTaskID, result)
+}
+
+// AckTaskAndEnqueue acknowledges a single task in a TaskSet and enqueues follow up tasks as a single operation.
+// The tasks are only enqueued if the ack succeeds
+func (g *GrooveMaster) AckTaskAndEnqueue(taskSetID string, succeededTaskID string, result interface{}, tasks []groove.Task) error {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	tasks, err := g.admit(tasks)
+	if err != nil {
+		return err
+	}
+
+	err = g.ackTask(taskSetID, succeededTaskID, result)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tasks {
+		g.putTask(t)
+	}
+
+	return nil
+}
+
+// ackTask is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) ackTask(taskSetID string, succeededTaskID string, result interface{}) error {
+	// Load the task set log
+	ts, ok := g.TaskSetLogs[taskSetID]
+	if ok {
+		acked := false
+
+		// Update task containers for each task
+		for i, taskID := range ts.TaskIDs {
+			if taskID == succeededTaskID {
+				idParts := strings.Split(taskID, ".")
+
+				// Find the TaskContainer that contains the current task
+				cc, _ := g.RootContainer.GetChildContainer(strings.Join(idParts[:len(idParts)-1], "."))
+				if cc != nil {
+					if cc.Locked && cc.LockedTask.ID == taskID {
+						cc.LockedTask.Result = result
+						cc.LockedTask.Succeeded = true
+
+						g.emit(groove.EventAcked, taskSetID, *cc.LockedTask)
+						g.finishTask(*cc.LockedTask)
+
+						cc.unlock(false, g.clock.Now())
+						cc.prune()
+
+						// Remove task from TaskSet
+						ts.TaskIDs = append(ts.TaskIDs[:i], ts.TaskIDs[i+1:]...)
+					} else {
+						return errors.New("task set was not locked")
+					}
+				}
+
+				acked = true
+
+				break
+			}
+		}
+
+		if !acked {
+			return errors.New("task did not exist")
+		}
+
+		// Remove the task set if there are no more tasks
+		if len(ts.TaskIDs) == 0 {
+			g.removeTaskSetLog(taskSetID)
+		} else {
+			g.putTaskSetLog(ts)
+		}
+	} else {
+		return errors.New("task set did not exist")
+	}
+
+	return nil
+}
+
+// Extend pushes back the timeout of an in flight task set, so that long running tasks can keep it alive.
+// The timeout that was granted is returned
+func (g *GrooveMaster) Extend(taskSetID string, timeout time.Duration) (time.Duration, error) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	ts, ok := g.TaskSetLogs[taskSetID]
+	if !ok {
+		return 0, errors.New("task set did not exist")
+	}
+
+	if lease := g.maxLease(ts.TaskIDs); lease > 0 && timeout > lease {
+		timeout = lease
+	}
+
+	ts.TimeoutAt = g.clock.Now().Add(timeout)
+	g.putTaskSetLog(ts)
+
+	return timeout, nil
+}
+
+func (g *GrooveMaster) Dequeue(desiredTasks int, prefix string, timeout time.Duration) *groove.TaskSet {
+	ts, _ := g.dequeue(desiredTasks, prefix, timeout, "")
+	return ts
+}
+
+// dequeue is Dequeue, recording the id of the api key that the task set belongs to.
+// An error is returned if the quota does not allow another task set
+func (g *GrooveMaster) dequeue(desiredTasks int, prefix string, timeout time.Duration, owner string) (*groove.TaskSet, error) {
+	start := time.Now()
+
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	defer func() {
+		g.metrics.dequeueDuration.observe(time.Since(start).Seconds())
+	}()
+
+	if g.draining {
+		return nil, ErrDraining
+	}
+
+	if err := g.checkDequeueQuota(); err != nil {
+		return nil, err
+	}
+
+	if timeout <= 0 {
+		timeout = time.Duration(*g.policyFor(prefix).DequeueTimeout)
+	}
+
+	var tasks []groove.Task
+	var taskIDs []string
+
+	var tc *TaskContainer
+
+	if prefix != "" {
+		tc, _ = g.RootContainer.GetChildContainer(prefix)
+	} else {
+		tc = g.RootContainer
+	}
+
+	if tc == nil {
+		return nil, nil
+	}
+
+	// Nothing can be dequeued from below a paused container
+	for n := tc.Parent; n != nil; n = n.Parent {
+		if n.Paused {
+			return nil, nil
+		}
+	}
+
+	id := uuid.Must(uuid.NewRandom()).String()
+	now := g.clock.Now()
+
+	// Policies of the containers above tc limit what can be dequeued from it too
+	var above []*TaskContainer
+	for n := tc; n != nil; n = n.Parent {
+		above = append([]*TaskContainer{n}, above...)
+	}
+
+	policies := g.policies.path(strings.Split(prefix, "."))
+	if prefix == "" {
+		policies = policies[:1]
+	}
+
+	for len(policies) < len(above) {
+		policies = append(policies, nil)
+	}
+
+	for {
+		var task *groove.Task
+
+		blocked := false
+		for i := 0; i < len(above)-1; i++ {
+			blocked = blocked || policies[i].blocks(above[i], now)
+		}
+
+		if !blocked {
+			task = tc.treePop(id, policies[len(above)-1], now)
+		}
+
+		if task != nil {
+			for i := 0; i < len(above)-1; i++ {
+				policies[i].took(now)
+			}
+		}
+
+		if task != nil {
+			tasks = append(tasks, *task)
+			taskIDs = append(taskIDs, task.ID)
+		} else {
+			break
+		}
+
+		if len(tasks) >= desiredTasks {
+			break
+		}
+	}
+
+	// Don't create a task set if there are no tasks
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+
+	if lease := g.maxLease(taskIDs); lease > 0 && timeout > lease {
+		timeout = lease
+	}
+
+	ts := groove.TaskSet{
+		ID:      id,
+		Tasks:   tasks,
+		Timeout: int(timeout.Milliseconds()),
+	}
+
+	tsl := groove.TaskSetLog{
+		ID:        id,
+		TaskIDs:   taskIDs,
+		TimeoutAt: now.Add(timeout),
+		Owner:     owner,
+	}
+
+	g.putTaskSetLog(tsl)
+
+	for _, t := range tasks {
+		g.metrics.taskWait.observe(now.Sub(t.EnqueuedAt).Seconds())
+		g.emit(groove.EventDequeued, id, t)
+	}
+
+	return &ts, nil
+}
+
+// finishTask is called once a task has either succeeded or permanently failed, it completes any waits
+// and batches that depend on the task.
+// finishTask is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) finishTask(task groove.Task) {
+	// Check for waits and complete them
+	if waits, ok := g.Waits[task.ID]; ok {
+		for _, w := range waits {
+			w <- task
+		}
+
+		delete(g.Waits, task.ID)
+	}
+
+	if task.BatchID != "" {
+		g.finishBatchTask(task)
+	}
+}
+
+// putTask is not safe to be called on it's own. The caller must ensure thread safety
+// putTask returns false if the task id could not be grooved
+func (g *GrooveMaster) putTask(task groove.Task) bool {
+	idParts := strings.Split(task.ID, ".")
+
+	// Check that the id has 2 or more parts, since the last part does not get grooved
+	if len(idParts) > 1 {
+		// Verify all maps are in place in the tree
+		var tc = g.RootContainer
+
+		for i, IDp := range idParts {
+			if i != len(idParts)-1 {
+				tcn, ok := tc.Children[IDp]
+				if !ok {
+					newTaskContainer := &TaskContainer{
+						Parent:   tc,
+						Children: map[string]*TaskContainer{},
+						Tasks:    nil,
+						key:      IDp,
+						Paused:   g.paused[strings.Join(idParts[:i+1], ".")],
+					}
+
+					tc.Children[IDp] = newTaskContainer
+
+					tcn = newTaskContainer
+				}
+
+				tc = tcn
+			} else {
+				// Restored tasks do not keep their payload size, as it is not stored
+				if g.quota.MaxPayloadBytes > 0 && task.PayloadSize == 0 {
+					task.PayloadSize = payloadSize(task)
+				}
+
+				// Restored tasks keep the time they were first enqueued
+				if task.EnqueuedAt.IsZero() {
+					task.EnqueuedAt = g.clock.Now()
+				}
+
+				g.taskSeq++
+				task.Seq = g.taskSeq
+
+				tc.Tasks = append(tc.Tasks, task)
+				tc.taskAdded(task)
+				tc.addPayload(task.PayloadSize)
+				g.emit(groove.EventEnqueued, "", task)
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// emit publishes a task lifecycle event
+// emit is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) emit(eventType groove.EventType, taskSetID string, task groove.Task) {
+	e := groove.Event{
+		Type:      eventType,
+		TaskID:    task.ID,
+		TaskSetID: taskSetID,
+		Time:      g.clock.Now(),
+		Task:      task,
+	}
+
+	g.metrics.events[eventType]++
+
+	e = g.events.add(e)
+	g.webhooks.publish(e)
+	g.logEvent(e)
+}
+
+// lockedContainer finds the TaskContainer that has the given task locked, or nil if the task is not locked
+// lockedContainer is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) lockedContainer(taskID string) *TaskContainer {
+	idParts := strings.Split(taskID, ".")
+
+	cc, _ := g.RootContainer.GetChildContainer(strings.Join(idParts[:len(idParts)-1], "."))
+	if cc == nil || !cc.Locked || cc.LockedTask.ID != taskID {
+		return nil
+	}
+
+	return cc
+}
+
+// checkLocked returns an error if a task no longer holds the lock on its container, so that a whole task set
+// can be checked before any of it is changed.
+// checkLocked is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) checkLocked(taskIDs []string) error {
+	for _, taskID := range taskIDs {
+		idParts := strings.Split(taskID, ".")
+
+		cc, _ := g.RootContainer.GetChildContainer(strings.Join(idParts[:len(idParts)-1], "."))
+		if cc != nil && (!cc.Locked || cc.LockedTask.ID != taskID) {
+			return errors.New("task set was not locked")
+		}
+	}
+
+	return nil
+}
+
+// putWait is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) putWait(taskID string) chan groove.Task {
+	ch := make(chan groove.Task, 1)
+	g.Waits[taskID] = append(g.Waits[taskID], ch)
+	return ch
+}
+
+type TaskContainer struct {
+	Locked     bool         `json:"locked"`
+	LockedTask *groove.Task `json:"locked_task"` // The task which is currently being processed
+
+	CurrentTaskTimeout *time.Time     `json:"-"`
+	Parent             *TaskContainer `json:"-"`
+
+	Children map[string]*TaskContainer `json:"children"`
+	Tasks    []groove.Task             `json:"tasks"`
+
+	LockedBy string `json:"locked_by,omitempty"` // The id of the task set the locked task belongs to
+	Paused   bool   `json:"paused,omitempty"`    // Tasks in a paused container, or below it, are not dequeued
+
+	key string // The key of this container in its parent's children
+
+	// Counters covering this container and every container below it, kept up to date as tasks move through the tree
+	pending     int            // Tasks waiting to be dequeued
+	locked      int            // Containers with a locked task
+	taskSets    map[string]int // In flight task sets, with the number of containers each one has locked
+	oldest      time.Time      // Enqueue time of the oldest pending task, only valid when oldestDirty is false
+	oldestDirty bool
+	throughput  rate  // Tasks finished per second
+	payload     int64 // Payload bytes of stored tasks, only counted when there is a payload quota
+}
+
+func (t *TaskContainer) String() string {
+	var str string
+
+	if len(t.Tasks) > 0 {
+		str = fmt.Sprintf("\n	) %d tasks\n", len(t.Tasks))
+	}
+
+	for k, v := range t.Children {
+		str += fmt.Sprintf(".%s\n%s", k, v.String())
+	}
+
+	return str
+}
+
+// TreePop finds a pending task in this container or any container below it, and locks it for the given task set
+func (t *TaskContainer) TreePop(taskSetID string) (task *groove.Task) {
+	return t.treePop(taskSetID, nil, time.Time{})
+}
+
+// treePop is TreePop, skipping containers whose policy does not allow any more tasks to be dequeued.
+// node is the policy tree node for this container
+func (t *TaskContainer) treePop(taskSetID string, node *policyNode, now time.Time) *groove.Task {
+	if t.Paused || node.blocks(t, now) {
+		return nil
+	}
+
+	if len(t.Tasks) > 0 && !t.Locked {
+		task := t.Pop()
+		t.LockedTask = &task
+		t.Locked = true
+		t.LockedBy = taskSetID
+
+		for n := t; n != nil; n = n.Parent {
+			n.pending--
+			n.locked++
+
+			if n.taskSets == nil {
+				n.taskSets = map[string]int{}
+			}
+
+			n.taskSets[taskSetID]++
+
+			if !n.oldestDirty && !task.EnqueuedAt.After(n.oldest) {
+				n.oldestDirty = true
+			}
+		}
+
+		node.took(now)
+
+		return &task
+	}
+
+	for name, v := range t.Children {
+		ctp := v.treePop(taskSetID, node.child(name), now)
+
+		if ctp != nil {
+			node.took(now)
+			return ctp
+		}
+	}
+
+	return nil
+}
+
+// taskAdded updates counters after a task has been added to the container
+func (t *TaskContainer) taskAdded(task groove.Task) {
+	for n := t; n != nil; n = n.Parent {
+		n.pending++
+
+		if !n.oldestDirty && (n.oldest.IsZero() || task.EnqueuedAt.Before(n.oldest)) {
+			n.oldest = task.EnqueuedAt
+		}
+	}
+}
+
+// unlock releases the locked task. When requeue is true the task is placed back at the front of the queue,
+// otherwise it is counted as finished
+func (t *TaskContainer) unlock(requeue bool, now time.Time) {
+	task := *t.LockedTask
+	taskSetID := t.LockedBy
+
+	t.LockedTask = nil
+	t.Locked = false
+	t.LockedBy = ""
+
+	for n := t; n != nil; n = n.Parent {
+		n.locked--
+
+		n.taskSets[taskSetID]--
+		if n.taskSets[taskSetID] <= 0 {
+			delete(n.taskSets, taskSetID)
+		}
+
+		if !requeue {
+			n.throughput.add(now, 1)
+		}
+	}
+
+	if requeue {
+		t.Tasks = append([]groove.Task{task}, t.Tasks...)
+		t.taskAdded(task)
+	} else {
+		t.addPayload(-task.PayloadSize)
+	}
+}
+
+// prune removes this container, and any parents, from the tree while they are empty
+func (t *TaskContainer) prune() {
+	for n := t; n.Parent != nil; n = n.Parent {
+		if len(n.Tasks) > 0 || n.Locked || len(n.Children) > 0 {
+			return
+		}
+
+		delete(n.Parent.Children, n.key)
+	}
+}
+
+// oldestPending returns the enqueue time of the oldest pending task in this container or below it,
+// or a zero time if there are no pending tasks
+func (t *TaskContainer) oldestPending() time.Time {
+	if !t.oldestDirty {
+		return t.oldest
+	}
+
+	var oldest time.Time
+
+	// Tasks are kept in the order they were enqueued, so the first one is the oldest
+	if len(t.Tasks) > 0 {
+		oldest = t.Tasks[0].EnqueuedAt
+	}
+
+	for _, child := range t.Children {
+		co := child.oldestPending()
+
+		if !co.IsZero() && (oldest.IsZero() || co.Before(oldest)) {
+			oldest = co
+		}
+	}
+
+	t.oldest = oldest
+	t.oldestDirty = false
+
+	return oldest
+}
+
+func (t *TaskContainer) Pop() (task groove.Task) {
+	task, t.Tasks = t.Tasks[0], t.Tasks[1:]
+	return task
+}
+
+func (t *TaskContainer) GetChildContainer(id string) (*TaskContainer, string) {
+	idParts := strings.Split(id, ".")
+
+	var tc = t
+	var idPart string
+
+	for _, idP := range idParts {
+		tcn, ok := tc.Children[idP]
+		if !ok {
+			return nil, ""
+		}
+
+		tc = tcn
+		idPart = idP
+	}
+
+	return tc, idPart
+}
@@ -1,8 +1,11 @@
-package main
+package server
 
 import (
 	"fmt"
+	"io/ioutil"
+	"log/slog"
 	"math/rand"
+	"os"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -13,6 +16,13 @@ import (
 
 var r *groove.TaskSet
 
+func TestMain(m *testing.M) {
+	// Task logs are far too noisy for the load tests
+	slog.SetDefault(slog.New(slog.NewTextHandler(ioutil.Discard, nil)))
+
+	os.Exit(m.Run())
+}
+
 func TestGrooveMaster_Enqueue(t *testing.T) {
 	g := New()
 
@@ -95,7 +105,7 @@ func TestGrooveMaster_EnqueueAndWait(t *testing.T) {
 				}
 			}
 
-			waits := g.EnqueueAndWait(tasks)
+			waits, _ := g.EnqueueAndWait(tasks)
 
 			eqwg.Done()
 
@@ -161,7 +171,7 @@ func TestGrooveMaster_Dequeue(t *testing.T) {
 		}
 	}()
 
-	waits := g.EnqueueAndWait([]groove.Task{
+	waits, _ := g.EnqueueAndWait([]groove.Task{
 		{
 			ID:             "test.task",
 			Data:           nil,
@@ -182,6 +192,191 @@ func TestGrooveMaster_Dequeue(t *testing.T) {
 	}
 }
 
+func TestGrooveMaster_AckAndEnqueue(t *testing.T) {
+	g := New()
+
+	g.Enqueue([]groove.Task{{ID: "chain.first"}})
+
+	dq := g.Dequeue(1, "chain", 10*time.Second)
+	if dq == nil {
+		t.Error("expected a task set")
+		return
+	}
+
+	err := g.AckAndEnqueue(dq.ID, nil, []groove.Task{{ID: "chain.second"}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, ok := g.TaskSetLogs[dq.ID]; ok {
+		t.Error("expected task set log to be removed after ack")
+		return
+	}
+
+	dq = g.Dequeue(1, "chain", 10*time.Second)
+	if dq == nil || dq.Tasks[0].ID != "chain.second" {
+		t.Error("expected follow up task to be enqueued")
+		return
+	}
+
+	// A failed ack must not enqueue anything
+	err = g.AckAndEnqueue("missing", nil, []groove.Task{{ID: "chain.third"}})
+	if err == nil {
+		t.Error("expected ack of missing task set to fail")
+		return
+	}
+
+	err = g.AckTaskAndEnqueue(dq.ID, "chain.second", nil, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if dq = g.Dequeue(1, "chain", 10*time.Second); dq != nil {
+		t.Error("expected no tasks after failed ack and enqueue")
+	}
+}
+
+func TestGrooveMaster_EnqueueBatch(t *testing.T) {
+	g := New()
+
+	var tasks []groove.Task
+
+	for i := 0; i < 10; i++ {
+		tasks = append(tasks, groove.Task{ID: fmt.Sprintf("job.%d.run", i)})
+	}
+
+	err := g.EnqueueBatch("b1", &groove.BatchCallback{Prefix: "done", Data: "ctx"}, tasks)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for {
+		dq := g.Dequeue(3, "job", 10*time.Second)
+		if dq == nil {
+			break
+		}
+
+		for _, task := range dq.Tasks {
+			if task.ID == "job.0.run" {
+				err = g.NackTask(dq.ID, task.ID, "broken")
+			} else {
+				err = g.AckTask(dq.ID, task.ID, task.ID)
+			}
+
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}
+
+	b, ok := g.Batch("b1")
+	if !ok {
+		t.Error("expected batch to exist")
+		return
+	}
+
+	if !b.Complete || b.Pending != 0 || b.Succeeded != 9 || b.Failed != 1 {
+		t.Errorf("unexpected batch progress %+v", b)
+		return
+	}
+
+	err = g.EnqueueBatch("b1", nil, tasks)
+	if err == nil {
+		t.Error("expected enqueue into a completed batch to fail")
+		return
+	}
+
+	dq := g.Dequeue(1, "done", 10*time.Second)
+	if dq == nil || dq.Tasks[0].ID != "done.b1" {
+		t.Error("expected callback task to be enqueued")
+		return
+	}
+
+	res, ok := dq.Tasks[0].Data.(groove.BatchResult)
+	if !ok {
+		t.Error("expected callback data to be a batch result")
+		return
+	}
+
+	if len(res.Results) != 9 || len(res.Errors["job.0.run"]) != 1 || res.Data != "ctx" {
+		t.Errorf("unexpected batch result %+v", res)
+	}
+}
+
+func TestGrooveMaster_EnqueueBatchID(t *testing.T) {
+	g := New()
+
+	err := g.EnqueueBatch("b2", nil, []groove.Task{{ID: "owned.a.1"}, {ID: "owned.b.1"}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// A batch id given to a plain enqueue must not count towards the batch
+	g.Enqueue([]groove.Task{{ID: "foreign.a.1", BatchID: "b2"}})
+
+	dq := g.Dequeue(1, "foreign", 10*time.Second)
+	if dq == nil || dq.Tasks[0].BatchID != "" {
+		t.Error("expected the task to be dequeued without a batch id")
+		return
+	}
+
+	if err := g.Ack(dq.ID, nil); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if b, ok := g.Batch("b2"); !ok || b.Pending != 2 || b.Complete {
+		t.Errorf("expected the batch to still have 2 pending tasks, got %+v", b)
+	}
+}
+
+func TestGrooveMaster_AckNotLocked(t *testing.T) {
+	g := New()
+
+	g.Enqueue([]groove.Task{{ID: "partial.a.1"}, {ID: "partial.b.1"}})
+
+	dq := g.Dequeue(2, "partial", 10*time.Second)
+	if dq == nil || len(dq.Tasks) != 2 {
+		t.Error("expected a task set with 2 tasks")
+		return
+	}
+
+	// Make the task set hold a task that no longer has the lock, an ack must then change nothing
+	cc, _ := g.RootContainer.GetChildContainer("partial.b")
+	cc.LockedTask.ID = "partial.b.2"
+
+	if err := g.Ack(dq.ID, nil); err == nil {
+		t.Error("expected the ack to fail")
+		return
+	}
+
+	if g.lockedContainer("partial.a.1") == nil {
+		t.Error("expected partial.a.1 to stay locked after the failed ack")
+		return
+	}
+
+	if err := g.Nack(dq.ID, nil); err == nil {
+		t.Error("expected the nack to fail")
+		return
+	}
+
+	if a := g.lockedContainer("partial.a.1"); a == nil || a.LockedTask.RetryCount != 0 {
+		t.Error("expected partial.a.1 to stay locked without a retry after the failed nack")
+		return
+	}
+
+	cc.LockedTask.ID = "partial.b.1"
+
+	if err := g.Ack(dq.ID, nil); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestGrooveMaster_PutTask(t *testing.T) {
 	g := New()
 
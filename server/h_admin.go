@@ -0,0 +1,188 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func (g *GrooveMaster) hPause(c *gin.Context) {
+	var input groove.PrefixInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !g.authorize(c, groove.PermissionAdmin, input.Prefix) {
+		return
+	}
+
+	g.Pause(input.Prefix)
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (g *GrooveMaster) hResume(c *gin.Context) {
+	var input groove.PrefixInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !g.authorize(c, groove.PermissionAdmin, input.Prefix) {
+		return
+	}
+
+	g.Resume(input.Prefix)
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (g *GrooveMaster) hListPaused(c *gin.Context) {
+	if !g.authorize(c, groove.PermissionRead, "") {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "ok",
+		"prefixes": g.Paused(),
+	})
+}
+
+func (g *GrooveMaster) hCancel(c *gin.Context) {
+	var input groove.CancelInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !g.authorize(c, groove.PermissionAdmin, input.TaskID) {
+		return
+	}
+
+	err = g.Cancel(input.TaskID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (g *GrooveMaster) hPurge(c *gin.Context) {
+	var input groove.PrefixInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !g.authorize(c, groove.PermissionAdmin, input.Prefix) {
+		return
+	}
+
+	purged, err := g.Purge(input.Prefix)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"purged": purged,
+	})
+}
+
+func (g *GrooveMaster) hListTaskSets(c *gin.Context) {
+	if !g.authorize(c, groove.PermissionRead, "") {
+		return
+	}
+
+	limit, ok := browseLimit(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "ok",
+		"task_sets": g.TaskSets(limit),
+	})
+}
+
+func (g *GrooveMaster) hListDeadLetters(c *gin.Context) {
+	if !g.authorize(c, groove.PermissionRead, c.Query("prefix")) {
+		return
+	}
+
+	limit, ok := browseLimit(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       "ok",
+		"dead_letters": g.DeadLetters(c.Query("prefix"), limit),
+	})
+}
+
+func (g *GrooveMaster) hRetryDeadLetters(c *gin.Context) {
+	var input groove.DeadLettersInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !g.authorize(c, groove.PermissionAdmin, deadLetterTargets(input)...) {
+		return
+	}
+
+	retried, err := g.RetryDeadLetters(input.Prefix, input.TaskIDs)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"retried": retried,
+	})
+}
+
+func (g *GrooveMaster) hPurgeDeadLetters(c *gin.Context) {
+	var input groove.DeadLettersInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !g.authorize(c, groove.PermissionAdmin, deadLetterTargets(input)...) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"purged": g.PurgeDeadLetters(input.Prefix, input.TaskIDs),
+	})
+}
+
+// deadLetterTargets lists what a dead letter operation acts on, for authorization
+func deadLetterTargets(input groove.DeadLettersInput) []string {
+	if len(input.TaskIDs) > 0 {
+		return input.TaskIDs
+	}
+
+	return []string{input.Prefix}
+}
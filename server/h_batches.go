@@ -0,0 +1,27 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func (g *GrooveMaster) hGetBatch(c *gin.Context) {
+	// A batch can only be seen by keys that could have enqueued it
+	if !g.authorize(c, groove.PermissionEnqueue, g.batchPrefixes(c.Param("id"))...) {
+		return
+	}
+
+	batch, ok := g.Batch(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "batch did not exist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"batch":  batch,
+	})
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+const (
+	defaultBrowseLimit = 100
+	maxBrowseLimit     = 1000
+)
+
+func (g *GrooveMaster) hBrowsePrefixes(c *gin.Context) {
+	limit, ok := browseLimit(c)
+	if !ok {
+		return
+	}
+
+	if !g.authorize(c, groove.PermissionRead, c.Query("prefix")) {
+		return
+	}
+
+	res, err := g.BrowsePrefixes(c.Query("prefix"), c.Query("cursor"), limit)
+	if err != nil {
+		browseError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (g *GrooveMaster) hBrowseTasks(c *gin.Context) {
+	limit, ok := browseLimit(c)
+	if !ok {
+		return
+	}
+
+	if !g.authorize(c, groove.PermissionRead, c.Query("prefix")) {
+		return
+	}
+
+	res, err := g.BrowseTasks(c.Query("prefix"), c.Query("cursor"), limit, c.Query("redact") == "true")
+	if err != nil {
+		browseError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// browseLimit reads the limit query parameter, responding with an error if it is invalid
+func browseLimit(c *gin.Context) (int, bool) {
+	if c.Query("limit") == "" {
+		return defaultBrowseLimit, true
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 || limit > maxBrowseLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+		return 0, false
+	}
+
+	return limit, true
+}
+
+func browseError(c *gin.Context, err error) {
+	if err == errPrefixNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// hEvents streams task lifecycle events as server sent events.
+// Events can be filtered with the prefix and type (comma separated) query parameters.
+// By default only new events are streamed, a Last-Event-ID header or since query parameter resumes from an earlier event
+func (g *GrooveMaster) hEvents(c *gin.Context) {
+	prefix := c.Query("prefix")
+
+	if !g.authorize(c, groove.PermissionRead, prefix) {
+		return
+	}
+
+	var types []groove.EventType
+
+	if c.Query("type") != "" {
+		for _, et := range strings.Split(c.Query("type"), ",") {
+			if !validEventType(groove.EventType(et)) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown event type " + et})
+				return
+			}
+
+			types = append(types, groove.EventType(et))
+		}
+	}
+
+	seq := g.events.head()
+
+	resume := c.GetHeader("Last-Event-ID")
+	if resume == "" {
+		resume = c.Query("since")
+	}
+
+	if resume != "" {
+		last, err := strconv.ParseUint(resume, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event id"})
+			return
+		}
+
+		seq = last + 1
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		events, missed, wait := g.events.since(seq)
+
+		if missed > 0 {
+			c.Render(-1, sse.Event{
+				Event: "missed",
+				Data:  gin.H{"missed": missed},
+			})
+		}
+
+		for _, e := range events {
+			seq = e.Seq + 1
+
+			if !eventMatches(e, prefix, types) {
+				continue
+			}
+
+			c.Render(-1, sse.Event{
+				Id:    strconv.FormatUint(e.Seq, 10),
+				Event: string(e.Type),
+				Data:  e,
+			})
+		}
+
+		c.Writer.Flush()
+
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, _ = c.Writer.WriteString(": keep-alive\n\n")
+		case <-wait:
+		}
+	}
+}
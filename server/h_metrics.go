@@ -0,0 +1,39 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// hMetrics serves prometheus metrics. The depth query parameter controls how many parts of a task id are used
+// to break down queue depth, it defaults to the metrics_prefix_depth setting
+func (g *GrooveMaster) hMetrics(c *gin.Context) {
+	if !g.authorize(c, groove.PermissionRead, "") {
+		return
+	}
+
+	g.mx.Lock()
+	depth := g.config.MetricsPrefixDepth
+	g.mx.Unlock()
+
+	if depthTxt := c.Query("depth"); depthTxt != "" {
+		d, err := strconv.Atoi(depthTxt)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "depth cannot be negative"})
+			return
+		}
+
+		depth = d
+	}
+
+	var buf bytes.Buffer
+
+	g.WriteMetrics(&buf, depth)
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
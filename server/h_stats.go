@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// hStats reports statistics for a prefix, and for each of its descendants depth levels below it (default 1)
+func (g *GrooveMaster) hStats(c *gin.Context) {
+	if !g.authorize(c, groove.PermissionRead, c.Query("prefix")) {
+		return
+	}
+
+	depth := 1
+
+	if c.Query("depth") != "" {
+		d, err := strconv.Atoi(c.Query("depth"))
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "depth cannot be negative"})
+			return
+		}
+
+		depth = d
+	}
+
+	stats, children, ok := g.Stats(c.Query("prefix"), depth)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "prefix did not exist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "ok",
+		"stats":    stats,
+		"children": children,
+	})
+}
@@ -0,0 +1,334 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func (g *GrooveMaster) hEnqueue(c *gin.Context) {
+	var input groove.EnqueueTaskInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(input.Tasks) > g.limits.MaxEnqueueTasks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("cannot enqueue more than %d tasks", g.limits.MaxEnqueueTasks)})
+		return
+	}
+
+	targets := taskIDs(input.Tasks)
+	if input.BatchID != "" && input.Callback != nil {
+		targets = append(targets, input.Callback.Prefix)
+	}
+
+	if !g.authorize(c, groove.PermissionEnqueue, targets...) {
+		return
+	}
+
+	wait := c.Query("wait") == "true"
+
+	ctx, span := tracer.Start(c.Request.Context(), "GrooveMaster.Enqueue", trace.WithAttributes(
+		attribute.Int("groove.task_count", len(input.Tasks)),
+		attribute.Bool("groove.wait", wait),
+		attribute.String("groove.batch_id", input.BatchID),
+	))
+	defer span.End()
+
+	// Tasks from producers that do not propagate a trace continue this one
+	injectTraceContext(ctx, input.Tasks)
+
+	if input.BatchID != "" {
+		if wait {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cannot wait on a batch, use the batch status or a callback instead"})
+			return
+		}
+
+		err = g.EnqueueBatch(input.BatchID, input.Callback, input.Tasks)
+		if err != nil {
+			span.RecordError(err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status":   "processed",
+			"enqueued": len(input.Tasks),
+			"batch_id": input.BatchID,
+		})
+		return
+	}
+
+	var fails int
+	var successes int
+
+	var tasks []groove.Task
+
+	if wait {
+		waits, err := g.EnqueueAndWait(input.Tasks)
+		if err != nil {
+			span.RecordError(err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		for _, w := range waits {
+			task, ok := <-w
+
+			// The wait was released by a shutdown, the tasks are still queued
+			if !ok {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": ErrDraining.Error() + ", the tasks are still queued"})
+				return
+			}
+
+			if task.Succeeded {
+				successes++
+			} else {
+				fails++
+			}
+
+			tasks = append(tasks, task)
+		}
+	} else {
+		err = g.Enqueue(input.Tasks)
+		if err != nil {
+			span.RecordError(err)
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	resp := gin.H{"status": "ok"}
+
+	if wait {
+		resp["processed"] = successes
+		resp["failed"] = fails
+		resp["tasks"] = tasks
+
+		if fails > 0 {
+			resp["status"] = "has_failures"
+		} else {
+			resp["status"] = "processed"
+		}
+	} else {
+		resp["enqueued"] = len(input.Tasks)
+		resp["status"] = "processed"
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (g *GrooveMaster) hDequeue(c *gin.Context) {
+	var input groove.DequeueTaskInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.DesiredTaskCount > g.limits.MaxDequeueTasks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("cannot dequeue more than %d tasks", g.limits.MaxDequeueTasks)})
+		return
+	}
+
+	if !g.authorize(c, groove.PermissionDequeue, input.Prefix) {
+		return
+	}
+
+	start := time.Now()
+
+	taskSet, err := g.dequeue(input.DesiredTaskCount, input.Prefix, time.Duration(input.Timeout)*time.Millisecond, requestKeyID(c))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	opts := []trace.SpanStartOption{
+		trace.WithTimestamp(start),
+		trace.WithAttributes(
+			attribute.String("groove.prefix", input.Prefix),
+			attribute.Int("groove.desired_task_count", input.DesiredTaskCount),
+		),
+	}
+
+	if taskSet != nil {
+		opts = append(opts, taskLinks(taskSet.Tasks), trace.WithAttributes(
+			attribute.String("groove.task_set_id", taskSet.ID),
+			attribute.Int("groove.task_count", len(taskSet.Tasks)),
+		))
+	}
+
+	_, span := tracer.Start(c.Request.Context(), "GrooveMaster.Dequeue", opts...)
+	span.End()
+
+	// A task set could not be formed due to not enough tasks
+	if taskSet == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status": "no_tasks_available",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "ok",
+		"task_set": taskSet,
+	})
+}
+
+func (g *GrooveMaster) hAck(c *gin.Context) {
+	var input groove.AckInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(input.Enqueue) > g.limits.MaxEnqueueTasks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("cannot enqueue more than %d tasks", g.limits.MaxEnqueueTasks)})
+		return
+	}
+
+	if !g.authorize(c, groove.PermissionDequeue) || !g.authorizeTaskSet(c, input.TaskSetID) {
+		return
+	}
+
+	if len(input.Enqueue) > 0 && !g.authorize(c, groove.PermissionEnqueue, taskIDs(input.Enqueue)...) {
+		return
+	}
+
+	ctx, span := tracer.Start(c.Request.Context(), "GrooveMaster.Ack", trace.WithAttributes(ackAttributes(input)...))
+
+	injectTraceContext(ctx, input.Enqueue)
+
+	if input.TaskID != nil {
+		err = g.AckTaskAndEnqueue(input.TaskSetID, *input.TaskID, input.Result, input.Enqueue)
+	} else {
+		err = g.AckAndEnqueue(input.TaskSetID, input.Result, input.Enqueue)
+	}
+
+	endSpan(span, err)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := gin.H{"status": "ok"}
+
+	if len(input.Enqueue) > 0 {
+		resp["enqueued"] = len(input.Enqueue)
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (g *GrooveMaster) hNack(c *gin.Context) {
+	var input groove.AckInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !g.authorize(c, groove.PermissionDequeue) || !g.authorizeTaskSet(c, input.TaskSetID) {
+		return
+	}
+
+	_, span := tracer.Start(c.Request.Context(), "GrooveMaster.Nack", trace.WithAttributes(ackAttributes(input)...))
+
+	if input.TaskID != nil {
+		err = g.NackTask(input.TaskSetID, *input.TaskID, input.Error)
+	} else {
+		err = g.Nack(input.TaskSetID, input.Error)
+	}
+
+	endSpan(span, err)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (g *GrooveMaster) hExtend(c *gin.Context) {
+	var input groove.ExtendInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Timeout <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "timeout must be greater than 0"})
+		return
+	}
+
+	if !g.authorize(c, groove.PermissionDequeue) || !g.authorizeTaskSet(c, input.TaskSetID) {
+		return
+	}
+
+	timeout, err := g.Extend(input.TaskSetID, time.Duration(input.Timeout)*time.Millisecond)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "timeout": timeout.Milliseconds()})
+}
+
+// errorStatus is the http status for an error returned by the GrooveMaster
+func errorStatus(err error) int {
+	if errors.Is(err, ErrQuotaExceeded) {
+		return http.StatusTooManyRequests
+	}
+
+	if errors.Is(err, ErrPayloadTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	if errors.Is(err, ErrDraining) {
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusBadRequest
+}
+
+func taskIDs(tasks []groove.Task) []string {
+	ids := make([]string, len(tasks))
+
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+
+	return ids
+}
+
+func ackAttributes(input groove.AckInput) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.String("groove.task_set_id", input.TaskSetID)}
+
+	if input.TaskID != nil {
+		attrs = append(attrs, attribute.String("groove.task_id", *input.TaskID))
+	}
+
+	if len(input.Enqueue) > 0 {
+		attrs = append(attrs, attribute.Int("groove.enqueue_count", len(input.Enqueue)))
+	}
+
+	return attrs
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func (g *GrooveMaster) hAddWebhook(c *gin.Context) {
+	if !g.authorize(c, groove.PermissionAdmin, "") {
+		return
+	}
+
+	var input groove.WebhookSubscription
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub, err := g.AddWebhook(input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub.Secret = ""
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"webhook": sub,
+	})
+}
+
+func (g *GrooveMaster) hListWebhooks(c *gin.Context) {
+	if !g.authorize(c, groove.PermissionAdmin, "") {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "ok",
+		"webhooks": g.Webhooks(),
+	})
+}
+
+func (g *GrooveMaster) hRemoveWebhook(c *gin.Context) {
+	if !g.authorize(c, groove.PermissionAdmin, "") {
+		return
+	}
+
+	if !g.RemoveWebhook(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook did not exist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
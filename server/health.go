@@ -0,0 +1,97 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// minTickAge is the least time the background loop can go without running before groove is considered dead.
+// Slower scan intervals allow a few missed ticks instead, see maxTickAge
+const minTickAge = 5 * time.Second
+
+// maxTickAge is how long the background loop can go without running before groove is considered dead
+func (g *GrooveMaster) maxTickAge() time.Duration {
+	if age := 3 * g.scanInterval; age > minTickAge {
+		return age
+	}
+
+	return minTickAge
+}
+
+// Health reports if the GrooveMaster is alive and able to accept writes
+func (g *GrooveMaster) Health() groove.Health {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	h := groove.Health{
+		Namespace: g.namespace,
+		LastTick:  g.lastTick,
+		Draining:  g.draining,
+	}
+
+	select {
+	case <-g.done:
+	default:
+		h.Alive = g.clock.Now().Sub(g.lastTick) < g.maxTickAge()
+	}
+
+	if g.storage != nil {
+		h.Storage = &groove.StorageHealth{
+			Healthy:    g.saveErr == nil,
+			LastSaveAt: g.lastSave,
+		}
+
+		if g.saveErr != nil {
+			h.Storage.Error = g.saveErr.Error()
+		}
+	}
+
+	h.Writable = h.Alive && !h.Draining && (h.Storage == nil || h.Storage.Healthy)
+
+	return h
+}
+
+// recordSave keeps the outcome of saving a snapshot for the health checks
+func (g *GrooveMaster) recordSave(err error) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	g.saveErr = err
+
+	if err == nil {
+		g.lastSave = g.clock.Now()
+	}
+}
+
+// registerHealth adds /healthz and /readyz, which report on every GrooveMaster returned by grooves.
+// They are public so that orchestrators can use them without an api key.
+// /healthz fails if the timeout loop of any of them has stopped, a restart is then needed.
+// /readyz also fails if any of them cannot accept writes, because it is draining or cannot save snapshots
+func registerHealth(router gin.IRouter, grooves func() []*GrooveMaster) {
+	check := func(ok func(h groove.Health) bool) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			res := groove.HealthResponse{Status: "ok"}
+			status := http.StatusOK
+
+			for _, g := range grooves() {
+				h := g.Health()
+
+				if !ok(h) {
+					res.Status = "unavailable"
+					status = http.StatusServiceUnavailable
+				}
+
+				res.Namespaces = append(res.Namespaces, h)
+			}
+
+			c.JSON(status, res)
+		}
+	}
+
+	router.GET("/healthz", check(func(h groove.Health) bool { return h.Alive }))
+	router.GET("/readyz", check(func(h groove.Health) bool { return h.Writable }))
+}
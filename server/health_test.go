@@ -0,0 +1,123 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// brokenStorage fails to save once it is broken
+type brokenStorage struct {
+	broken atomic.Bool
+}
+
+func (s *brokenStorage) Load() (*Snapshot, error) {
+	return nil, nil
+}
+
+func (s *brokenStorage) Save(*Snapshot) error {
+	if s.broken.Load() {
+		return errors.New("disk full")
+	}
+
+	return nil
+}
+
+func TestGrooveMaster_Health(t *testing.T) {
+	storage := &brokenStorage{}
+
+	g := New(WithStorage(storage, time.Millisecond))
+	defer g.Close()
+
+	srv := httptest.NewServer(g.Handler())
+	defer srv.Close()
+
+	expectStatus := func(path string, status int) bool {
+		t.Helper()
+
+		res, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Error(err)
+			return false
+		}
+
+		res.Body.Close()
+
+		if res.StatusCode != status {
+			t.Errorf("expected %s to be %d, got %d", path, status, res.StatusCode)
+			return false
+		}
+
+		return true
+	}
+
+	if !expectStatus("/healthz", http.StatusOK) || !expectStatus("/readyz", http.StatusOK) {
+		return
+	}
+
+	storage.broken.Store(true)
+
+	for deadline := time.Now().Add(5 * time.Second); g.Health().Storage.Healthy; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Error("expected the snapshot to fail to save")
+			return
+		}
+	}
+
+	if h := g.Health(); h.Storage.Error == "" || h.Writable {
+		t.Errorf("expected the failed save to be reported, got %+v", h.Storage)
+		return
+	}
+
+	// Failing to save makes groove unready, but it is still alive
+	if !expectStatus("/healthz", http.StatusOK) || !expectStatus("/readyz", http.StatusServiceUnavailable) {
+		return
+	}
+
+	draining := New()
+	defer draining.Close()
+
+	draining.StopDequeues()
+
+	if h := draining.Health(); !h.Alive || !h.Draining || h.Writable {
+		t.Errorf("expected a draining groove to be alive but not writable, got %+v", h)
+		return
+	}
+
+	_ = draining.Close()
+
+	if h := draining.Health(); h.Alive {
+		t.Errorf("expected a closed groove not to be alive, got %+v", h)
+	}
+}
+
+func TestGrooveMaster_HealthScanInterval(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ScanInterval = groove.Duration(10 * time.Second)
+
+	g := New(WithConfig(cfg))
+	defer g.Close()
+
+	// A slow scan interval leaves long gaps between ticks without the loop having stopped
+	g.mx.Lock()
+	g.lastTick = time.Now().Add(-15 * time.Second)
+	g.mx.Unlock()
+
+	if !g.Health().Alive {
+		t.Error("expected groove to be alive between ticks of a slow scan interval")
+		return
+	}
+
+	g.mx.Lock()
+	g.lastTick = time.Now().Add(-time.Minute)
+	g.mx.Unlock()
+
+	if g.Health().Alive {
+		t.Error("expected groove to be dead after missing several ticks")
+	}
+}
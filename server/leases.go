@@ -0,0 +1,168 @@
+package server
+
+import (
+	"container/heap"
+	"log/slog"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// lease is the time at which an in flight task set times out
+type lease struct {
+	taskSetID string
+	timeoutAt time.Time
+	index     int // Position in the heap, kept up to date by the heap
+}
+
+// leaseHeap is a min heap of leases ordered by when they time out, so that the next task set to expire
+// can be found without looking at every task set in flight
+type leaseHeap struct {
+	items []*lease
+	byID  map[string]*lease
+}
+
+func newLeaseHeap() *leaseHeap {
+	return &leaseHeap{byID: map[string]*lease{}}
+}
+
+func (h *leaseHeap) Len() int { return len(h.items) }
+
+func (h *leaseHeap) Less(i, j int) bool { return h.items[i].timeoutAt.Before(h.items[j].timeoutAt) }
+
+func (h *leaseHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+func (h *leaseHeap) Push(x interface{}) {
+	l := x.(*lease)
+	l.index = len(h.items)
+	h.items = append(h.items, l)
+}
+
+func (h *leaseHeap) Pop() interface{} {
+	n := len(h.items)
+	l := h.items[n-1]
+	h.items[n-1] = nil
+	h.items = h.items[:n-1]
+	l.index = -1
+
+	return l
+}
+
+// set adds or moves the lease of a task set, returning true if it is now the first to expire
+func (h *leaseHeap) set(taskSetID string, timeoutAt time.Time) bool {
+	if l, ok := h.byID[taskSetID]; ok {
+		l.timeoutAt = timeoutAt
+		heap.Fix(h, l.index)
+
+		return l.index == 0
+	}
+
+	l := &lease{taskSetID: taskSetID, timeoutAt: timeoutAt}
+	h.byID[taskSetID] = l
+	heap.Push(h, l)
+
+	return l.index == 0
+}
+
+// remove drops the lease of a task set, if it has one
+func (h *leaseHeap) remove(taskSetID string) {
+	l, ok := h.byID[taskSetID]
+	if !ok {
+		return
+	}
+
+	heap.Remove(h, l.index)
+	delete(h.byID, taskSetID)
+}
+
+// next returns when the first lease expires, false if there are no leases
+func (h *leaseHeap) next() (time.Time, bool) {
+	if len(h.items) == 0 {
+		return time.Time{}, false
+	}
+
+	return h.items[0].timeoutAt, true
+}
+
+// expired removes and returns the task sets whose leases had expired by now, in the order they expired
+func (h *leaseHeap) expired(now time.Time) []string {
+	var ids []string
+
+	for len(h.items) > 0 && now.After(h.items[0].timeoutAt) {
+		l := heap.Pop(h).(*lease)
+		delete(h.byID, l.taskSetID)
+
+		ids = append(ids, l.taskSetID)
+	}
+
+	return ids
+}
+
+// putTaskSetLog is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) putTaskSetLog(ts groove.TaskSetLog) {
+	old, ok := g.TaskSetLogs[ts.ID]
+	g.TaskSetLogs[ts.ID] = ts
+
+	if ok && old.TimeoutAt.Equal(ts.TimeoutAt) {
+		return
+	}
+
+	if g.leases.set(ts.ID, ts.TimeoutAt) {
+		g.wakeRun()
+	}
+}
+
+// removeTaskSetLog is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) removeTaskSetLog(taskSetID string) {
+	delete(g.TaskSetLogs, taskSetID)
+	g.leases.remove(taskSetID)
+}
+
+// expireLeases times out every task set whose lease has expired.
+// expireLeases is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) expireLeases() {
+	for _, id := range g.leases.expired(g.clock.Now()) {
+		err := g.timeout(id)
+		if err != nil {
+			g.logger.Warn("could not time out task set, releasing its tasks one at a time",
+				slog.String("task_set_id", id), slog.String("error", err.Error()))
+
+			g.releaseTaskSet(id)
+		}
+	}
+}
+
+// releaseTaskSet removes a task set whose lease has expired but that could not be timed out as a whole,
+// nacking each task it still holds so that no container is left locked without a lease.
+// releaseTaskSet is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) releaseTaskSet(taskSetID string) {
+	ts, ok := g.TaskSetLogs[taskSetID]
+	if !ok {
+		return
+	}
+
+	// nackTask changes the task ids of the log in place
+	taskIDs := append([]string(nil), ts.TaskIDs...)
+
+	for _, taskID := range taskIDs {
+		if cc := g.lockedContainer(taskID); cc != nil && cc.LockedBy == taskSetID {
+			_ = g.nackTask(taskSetID, taskID, map[string]string{
+				"error": "task failed due to exceeding timeout",
+			})
+		}
+	}
+
+	g.removeTaskSetLog(taskSetID)
+}
+
+// wakeRun tells the background loop that the first lease to expire has changed
+func (g *GrooveMaster) wakeRun() {
+	select {
+	case g.wake <- struct{}{}:
+	default:
+	}
+}
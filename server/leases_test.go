@@ -0,0 +1,126 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func TestLeaseHeap(t *testing.T) {
+	h := newLeaseHeap()
+	start := time.Now()
+
+	h.set("c", start.Add(3*time.Second))
+	h.set("a", start.Add(time.Second))
+	h.set("b", start.Add(2*time.Second))
+
+	if next, ok := h.next(); !ok || !next.Equal(start.Add(time.Second)) {
+		t.Errorf("expected the first lease to expire after a second, got %s", next.Sub(start))
+		return
+	}
+
+	// Extending a moves it behind the others, removing b leaves c first
+	if h.set("a", start.Add(4*time.Second)) {
+		t.Error("expected a not to be first to expire once extended")
+		return
+	}
+
+	h.remove("b")
+	h.remove("missing")
+
+	if ids := h.expired(start.Add(2 * time.Second)); len(ids) != 0 {
+		t.Errorf("expected nothing to have expired, got %v", ids)
+		return
+	}
+
+	ids := h.expired(start.Add(5 * time.Second))
+	if len(ids) != 2 || ids[0] != "c" || ids[1] != "a" {
+		t.Errorf("expected c then a to expire, got %v", ids)
+		return
+	}
+
+	if _, ok := h.next(); ok || len(h.byID) != 0 {
+		t.Error("expected no leases to be left")
+	}
+}
+
+func TestGrooveMaster_LeaseExpiry(t *testing.T) {
+	// A scan interval this long means only the lease timer can time the task set out
+	cfg := DefaultConfig()
+	cfg.ScanInterval = groove.Duration(time.Hour)
+
+	g := New(WithConfig(cfg))
+	defer g.Close()
+
+	g.Enqueue([]groove.Task{{ID: "lease.a.1", RetryThreshold: 1}, {ID: "lease.b.1", RetryThreshold: 1}})
+
+	short := g.Dequeue(1, "lease.a", 50*time.Millisecond)
+	long := g.Dequeue(1, "lease.b", time.Hour)
+
+	if short == nil || long == nil {
+		t.Error("expected both task sets to be dequeued")
+		return
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for {
+		g.mx.Lock()
+		_, inFlight := g.TaskSetLogs[short.ID]
+		g.mx.Unlock()
+
+		if !inFlight {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Error("expected the short task set to time out")
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	if _, ok := g.TaskSetLogs[long.ID]; !ok || g.leases.Len() != 1 {
+		t.Errorf("expected only the long task set to be left in flight, got %d leases", g.leases.Len())
+	}
+}
+
+func TestGrooveMaster_LeaseExpiryFailure(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+
+	g := New(WithClock(clock))
+	defer g.Close()
+
+	g.Enqueue([]groove.Task{{ID: "lease.c.1", RetryThreshold: 1}, {ID: "lease.d.1", RetryThreshold: 1}})
+
+	ts := g.Dequeue(2, "lease", time.Second)
+	if ts == nil || len(ts.Tasks) != 2 {
+		t.Error("expected a task set with 2 tasks")
+		return
+	}
+
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	// Make the task set hold a task that no longer has the lock, so that timing it out as a whole fails
+	cc, _ := g.RootContainer.GetChildContainer("lease.d")
+	cc.LockedTask.ID = "lease.d.2"
+
+	clock.advance(2 * time.Second)
+	g.expireLeases()
+
+	if len(g.TaskSetLogs) != 0 || g.leases.Len() != 0 {
+		t.Errorf("expected the task set to be removed, got %d task sets and %d leases", len(g.TaskSetLogs), g.leases.Len())
+		return
+	}
+
+	c, _ := g.RootContainer.GetChildContainer("lease.c")
+	if c == nil || c.Locked || len(c.Tasks) != 1 || c.Tasks[0].RetryCount != 1 {
+		t.Error("expected lease.c.1 to be unlocked and requeued with a retry")
+	}
+}
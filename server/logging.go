@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// NewLogger creates a json logger that writes to w, using the log level and sample rate of a config.
+// The sample rate is returned so it can be applied to high volume logs
+func NewLogger(w io.Writer, cfg Config) (*slog.Logger, int, error) {
+	var level slog.Level
+
+	err := level.UnmarshalText([]byte(cfg.LogLevel))
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid log_level: %w", err)
+	}
+
+	sampleRate := cfg.LogSampleRate
+	if sampleRate < 1 {
+		sampleRate = 1
+	}
+
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})), sampleRate, nil
+}
+
+// samplingHandler only passes on one in every rate records with the same message
+type samplingHandler struct {
+	slog.Handler
+
+	rate   uint64
+	counts *sync.Map // Record message -> *uint64
+}
+
+func newSamplingHandler(h slog.Handler, rate int) slog.Handler {
+	if rate <= 1 {
+		return h
+	}
+
+	return &samplingHandler{
+		Handler: h,
+		rate:    uint64(rate),
+		counts:  &sync.Map{},
+	}
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	c, _ := h.counts.LoadOrStore(r.Message, new(uint64))
+
+	if (atomic.AddUint64(c.(*uint64), 1)-1)%h.rate != 0 {
+		return nil
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithAttrs(attrs), rate: h.rate, counts: h.counts}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithGroup(name), rate: h.rate, counts: h.counts}
+}
+
+// SetLogger replaces the logger used by the GrooveMaster. Logs on high volume paths (enqueue, dequeue, ack)
+// are sampled, only one in every sampleRate of them is written
+func (g *GrooveMaster) SetLogger(logger *slog.Logger, sampleRate int) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	g.setLogger(logger, sampleRate)
+}
+
+// setLogger is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) setLogger(logger *slog.Logger, sampleRate int) {
+	g.logger = logger
+	g.sampledLogger = slog.New(newSamplingHandler(logger.Handler(), sampleRate))
+	g.sampleRate = sampleRate
+	g.webhooks.setLogger(logger)
+}
+
+// logEvent logs a task state transition
+// logEvent is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) logEvent(e groove.Event) {
+	logger := g.sampledLogger
+	level := slog.LevelInfo
+
+	switch e.Type {
+	case groove.EventNacked, groove.EventTimedOut:
+		logger = g.logger
+		level = slog.LevelWarn
+	case groove.EventDeadLettered:
+		logger = g.logger
+		level = slog.LevelError
+	}
+
+	ctx := context.Background()
+
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	logger.LogAttrs(ctx, level, "task "+strings.ReplaceAll(string(e.Type), "_", " "),
+		slog.String("outcome", string(e.Type)),
+		slog.String("task_id", e.TaskID),
+		slog.String("task_set_id", e.TaskSetID),
+		slog.String("prefix", taskPrefix(e.TaskID)),
+		slog.Int("retry_count", e.Task.RetryCount),
+	)
+}
+
+// RequestLogger logs every http request, requests that succeed are sampled
+func RequestLogger(logger *slog.Logger, sampleRate int) gin.HandlerFunc {
+	sampled := slog.New(newSamplingHandler(logger.Handler(), sampleRate))
+
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		status := c.Writer.Status()
+
+		l := sampled
+		level := slog.LevelInfo
+
+		if status >= 500 {
+			l = logger
+			level = slog.LevelError
+		} else if status >= 400 {
+			l = logger
+			level = slog.LevelWarn
+		}
+
+		l.LogAttrs(c.Request.Context(), level, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
+
+// taskPrefix returns the prefix of a task id, which is everything but the last part
+func taskPrefix(taskID string) string {
+	i := strings.LastIndex(taskID, ".")
+	if i < 0 {
+		return ""
+	}
+
+	return taskID[:i]
+}
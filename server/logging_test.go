@@ -0,0 +1,44 @@
+package server
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func TestGrooveMaster_SetLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	g := New()
+	g.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)), 5)
+
+	var tasks []groove.Task
+
+	for i := 0; i < 10; i++ {
+		tasks = append(tasks, groove.Task{ID: "logs.task"})
+	}
+
+	g.Enqueue(tasks)
+
+	dq := g.Dequeue(1, "logs", time.Second)
+
+	err := g.Nack(dq.ID, "failed")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	out := buf.String()
+
+	if n := strings.Count(out, `"msg":"task enqueued"`); n != 2 {
+		t.Errorf("expected enqueue logs to be sampled down to 2, got %d", n)
+	}
+
+	if !strings.Contains(out, `"msg":"task dead lettered","outcome":"dead_lettered","task_id":"logs.task","task_set_id":"`+dq.ID+`","prefix":"logs"`) {
+		t.Errorf("expected a dead lettered log with task context, got %s", out)
+	}
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// latencyBuckets are the upper bounds (in seconds) of the histogram buckets used for latencies
+var latencyBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900}
+
+// histogram is a prometheus style cumulative histogram
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+
+	h.sum += v
+	h.count++
+}
+
+// metrics are updated as tasks move through groove
+// metrics are not thread safe, they are protected by the GrooveMaster lock
+type metrics struct {
+	events          map[groove.EventType]uint64
+	dequeueDuration *histogram // How long calls to Dequeue take, including waiting for the lock
+	taskWait        *histogram // How long tasks wait between being enqueued and being dequeued
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		events:          map[groove.EventType]uint64{},
+		dequeueDuration: newHistogram(latencyBuckets),
+		taskWait:        newHistogram(latencyBuckets),
+	}
+}
+
+// prefixMetrics are the gauges reported for a single prefix
+type prefixMetrics struct {
+	pending int
+	locked  int
+}
+
+// WriteMetrics writes metrics in the prometheus text format. Queue depth and locked containers
+// are broken down by prefixes of up to prefixDepth parts, each prefix counts everything below it.
+// Every series is labelled with the namespace, so that namespaces scraped separately can be told apart
+func (g *GrooveMaster) WriteMetrics(buf *bytes.Buffer, prefixDepth int) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	now := g.clock.Now()
+	ns := escapeLabel(g.namespace)
+
+	prefixes := map[string]*prefixMetrics{}
+
+	// The counters of a container cover every task below it
+	var walk func(tc *TaskContainer, path string, depth int)
+	walk = func(tc *TaskContainer, path string, depth int) {
+		prefixes[path] = &prefixMetrics{pending: tc.pending, locked: tc.locked}
+
+		if depth == prefixDepth {
+			return
+		}
+
+		for k, child := range tc.Children {
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+
+			walk(child, childPath, depth+1)
+		}
+	}
+
+	walk(g.RootContainer, "", 0)
+
+	oldest := g.RootContainer.oldestPending()
+
+	var paths []string
+	for p := range prefixes {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+
+	writeHeader(buf, "groove_tasks_pending", "gauge", "Tasks waiting to be dequeued under a prefix")
+	for _, p := range paths {
+		fmt.Fprintf(buf, "groove_tasks_pending{namespace=\"%s\",prefix=\"%s\"} %d\n", ns, escapeLabel(p), prefixes[p].pending)
+	}
+
+	writeHeader(buf, "groove_containers_locked", "gauge", "Task containers under a prefix with a task currently being processed")
+	for _, p := range paths {
+		fmt.Fprintf(buf, "groove_containers_locked{namespace=\"%s\",prefix=\"%s\"} %d\n", ns, escapeLabel(p), prefixes[p].locked)
+	}
+
+	writeHeader(buf, "groove_events_total", "counter", "Task lifecycle events, by type")
+	for _, et := range groove.EventTypes {
+		fmt.Fprintf(buf, "groove_events_total{namespace=\"%s\",type=\"%s\"} %d\n", ns, et, g.metrics.events[et])
+	}
+
+	age := 0.0
+	if !oldest.IsZero() {
+		age = now.Sub(oldest).Seconds()
+	}
+
+	writeHeader(buf, "groove_oldest_pending_task_age_seconds", "gauge", "Age of the oldest task waiting to be dequeued")
+	fmt.Fprintf(buf, "groove_oldest_pending_task_age_seconds{namespace=\"%s\"} %g\n", ns, age)
+
+	writeHeader(buf, "groove_task_sets_in_flight", "gauge", "Task sets that have been dequeued but not yet acked or nacked")
+	fmt.Fprintf(buf, "groove_task_sets_in_flight{namespace=\"%s\"} %d\n", ns, len(g.TaskSetLogs))
+
+	writeHeader(buf, "groove_waits", "gauge", "Tasks that have an enqueue caller waiting on their result")
+	fmt.Fprintf(buf, "groove_waits{namespace=\"%s\"} %d\n", ns, len(g.Waits))
+
+	writeHeader(buf, "groove_webhook_deliveries_dropped_total", "counter", "Webhook deliveries dropped because the delivery queue was full")
+	fmt.Fprintf(buf, "groove_webhook_deliveries_dropped_total{namespace=\"%s\"} %d\n", ns, atomic.LoadUint64(&g.webhooks.dropped))
+
+	writeHistogram(buf, "groove_dequeue_duration_seconds", "Time taken to dequeue a task set", ns, g.metrics.dequeueDuration)
+	writeHistogram(buf, "groove_task_wait_seconds", "Time tasks spent waiting between being enqueued and dequeued", ns, g.metrics.taskWait)
+}
+
+func writeHeader(buf *bytes.Buffer, name string, kind string, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeHistogram(buf *bytes.Buffer, name string, help string, ns string, h *histogram) {
+	writeHeader(buf, name, "histogram", help)
+
+	for i, b := range h.buckets {
+		fmt.Fprintf(buf, "%s_bucket{namespace=\"%s\",le=\"%g\"} %d\n", name, ns, b, h.counts[i])
+	}
+
+	fmt.Fprintf(buf, "%s_bucket{namespace=\"%s\",le=\"+Inf\"} %d\n", name, ns, h.count)
+	fmt.Fprintf(buf, "%s_sum{namespace=\"%s\"} %g\n", name, ns, h.sum)
+	fmt.Fprintf(buf, "%s_count{namespace=\"%s\"} %d\n", name, ns, h.count)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
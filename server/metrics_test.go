@@ -0,0 +1,55 @@
+package server
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func TestGrooveMaster_WriteMetrics(t *testing.T) {
+	g := New()
+
+	g.Enqueue([]groove.Task{{ID: "a.b.1"}, {ID: "a.b.2"}, {ID: "a.c.1"}, {ID: "d.1"}})
+	g.Dequeue(1, "a.c", 10*time.Second)
+
+	var buf bytes.Buffer
+
+	g.WriteMetrics(&buf, 1)
+
+	out := buf.String()
+
+	for _, line := range []string{
+		`groove_tasks_pending{namespace="default",prefix="a"} 2`,
+		`groove_tasks_pending{namespace="default",prefix="d"} 1`,
+		`groove_containers_locked{namespace="default",prefix="a"} 1`,
+		`groove_events_total{namespace="default",type="enqueued"} 4`,
+		`groove_events_total{namespace="default",type="dequeued"} 1`,
+		`groove_task_sets_in_flight{namespace="default"} 1`,
+		`groove_dequeue_duration_seconds_count{namespace="default"} 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("expected metrics to contain %q", line)
+		}
+	}
+
+	// Every prefix counts the tasks below it, however deep the breakdown goes
+	buf.Reset()
+	g.WriteMetrics(&buf, 2)
+
+	out = buf.String()
+
+	for _, line := range []string{
+		`groove_tasks_pending{namespace="default",prefix=""} 3`,
+		`groove_tasks_pending{namespace="default",prefix="a"} 2`,
+		`groove_tasks_pending{namespace="default",prefix="a.b"} 2`,
+		`groove_containers_locked{namespace="default",prefix="a"} 1`,
+		`groove_containers_locked{namespace="default",prefix="a.c"} 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("expected metrics broken down by 2 parts to contain %q", line)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// namespaceName is the pattern that namespace names must match
+var namespaceName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// Namespaces serves several isolated queues from one server. Each namespace is its own GrooveMaster, with its
+// own tree of tasks, task sets and waits, and requests pick one with the namespace header
+type Namespaces struct {
+	mx      sync.Mutex
+	grooves map[string]*GrooveMaster
+	opts    func(name string) []Option
+
+	logger     *slog.Logger
+	sampleRate int
+}
+
+// NewNamespaces creates an empty set of namespaces. opts returns the options that a namespace is created with,
+// which lets namespaces share options such as api keys while storing their snapshots separately
+func NewNamespaces(logger *slog.Logger, sampleRate int, opts func(name string) []Option) *Namespaces {
+	return &Namespaces{
+		grooves:    map[string]*GrooveMaster{},
+		opts:       opts,
+		logger:     logger,
+		sampleRate: sampleRate,
+	}
+}
+
+// Create opens a namespace with a quota, restoring its snapshot if it has storage
+func (n *Namespaces) Create(name string, quota groove.Quota) (*GrooveMaster, error) {
+	if !namespaceName.MatchString(name) {
+		return nil, fmt.Errorf("invalid namespace name %q, names can only contain a-z, 0-9, _ and -", name)
+	}
+
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	if _, ok := n.grooves[name]; ok {
+		return nil, fmt.Errorf("namespace %s already exists", name)
+	}
+
+	var opts []Option
+	if n.opts != nil {
+		opts = n.opts(name)
+	}
+
+	g, err := Open(append(opts, withNamespace(name), WithQuota(quota))...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open namespace %s: %w", name, err)
+	}
+
+	n.grooves[name] = g
+
+	return g, nil
+}
+
+// Get returns a namespace, or nil if it does not exist
+func (n *Namespaces) Get(name string) *GrooveMaster {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	return n.grooves[name]
+}
+
+// Names lists every namespace in order
+func (n *Namespaces) Names() []string {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	names := make([]string, 0, len(n.grooves))
+	for name := range n.grooves {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Close closes every namespace
+func (n *Namespaces) Close() error {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	var errs []error
+
+	for _, g := range n.grooves {
+		errs = append(errs, g.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// Drain stops dequeues in every namespace, then drains each of them as GrooveMaster.Drain does
+func (n *Namespaces) Drain(ctx context.Context) error {
+	grooves := n.all()
+
+	for _, g := range grooves {
+		g.StopDequeues()
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(grooves))
+
+	for i, g := range grooves {
+		wg.Add(1)
+
+		go func(i int, g *GrooveMaster) {
+			defer wg.Done()
+
+			if err := g.Drain(ctx); err != nil {
+				errs[i] = fmt.Errorf("namespace %s: %w", g.namespace, err)
+			}
+		}(i, g)
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Handler returns an http.Handler serving the groove http api for every namespace, with request logging and tracing
+func (n *Namespaces) Handler() http.Handler {
+	r := gin.New()
+
+	r.Use(gin.Recovery(), RequestLogger(n.logger, n.sampleRate), TraceMiddleware)
+
+	n.RegisterRoutes(r)
+
+	return r
+}
+
+// RegisterRoutes adds the groove http api for every namespace to a router
+func (n *Namespaces) RegisterRoutes(router gin.IRouter) {
+	registerHealth(router, n.all)
+	registerRoutes(router, n.resolve)
+}
+
+// all returns every namespace in order
+func (n *Namespaces) all() []*GrooveMaster {
+	names := n.Names()
+	grooves := make([]*GrooveMaster, 0, len(names))
+
+	for _, name := range names {
+		if g := n.Get(name); g != nil {
+			grooves = append(grooves, g)
+		}
+	}
+
+	return grooves
+}
+
+// resolve finds the namespace that a request selected, from the namespace header or otherwise the namespace
+// query parameter (browsers cannot set headers on event streams). Requests for unknown namespaces are rejected
+func (n *Namespaces) resolve(c *gin.Context) *GrooveMaster {
+	name := c.GetHeader(groove.NamespaceHeader)
+	if name == "" {
+		name = c.Query("namespace")
+	}
+
+	if name == "" {
+		name = groove.DefaultNamespace
+	}
+
+	g := n.Get(name)
+	if g == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("namespace %s does not exist", name)})
+		return nil
+	}
+
+	return g
+}
+
+// withNamespace names the namespace that a GrooveMaster serves
+func withNamespace(name string) Option {
+	return func(g *GrooveMaster) {
+		g.namespace = name
+	}
+}
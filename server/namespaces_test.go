@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func TestNamespaces(t *testing.T) {
+	ns := NewNamespaces(slog.New(slog.NewTextHandler(io.Discard, nil)), 1, func(name string) []Option {
+		return []Option{WithAPIKeys([]groove.APIKey{
+			{ID: "all", Secret: "all-secret", Permissions: []groove.Permission{groove.PermissionAdmin}},
+			{ID: "team-a", Secret: "team-a-secret", Namespaces: []string{"team-a"}, Permissions: []groove.Permission{groove.PermissionAdmin}},
+		})}
+	})
+	defer ns.Close()
+
+	for name, quota := range map[string]groove.Quota{groove.DefaultNamespace: {}, "team-a": {MaxPendingTasks: 1}} {
+		if _, err := ns.Create(name, quota); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	if _, err := ns.Create("Team B", groove.Quota{}); err == nil {
+		t.Error("expected an invalid namespace name to be rejected")
+		return
+	}
+
+	srv := httptest.NewServer(ns.Handler())
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	def := groove.New(srv.URL, groove.WithAPIKey("all-secret"))
+	teamA := groove.New(srv.URL, groove.WithAPIKey("all-secret"), groove.WithNamespace("team-a"))
+
+	_, err := def.Enqueue(ctx, []groove.Task{{ID: "ns.a.1"}}, false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = teamA.Enqueue(ctx, []groove.Task{{ID: "ns.a.2"}}, false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Each namespace only sees its own tasks
+	res, err := teamA.Dequeue(ctx, groove.DequeueTaskInput{DesiredTaskCount: 10, Prefix: "ns.a", Timeout: 60000})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(res.TaskSet.Tasks) != 1 || res.TaskSet.Tasks[0].ID != "ns.a.2" {
+		t.Errorf("expected only ns.a.2 in team-a, got %+v", res.TaskSet.Tasks)
+		return
+	}
+
+	if ts := ns.Get(groove.DefaultNamespace).Dequeue(10, "ns.a", time.Minute); ts == nil || len(ts.Tasks) != 1 || ts.Tasks[0].ID != "ns.a.1" {
+		t.Errorf("expected only ns.a.1 in the default namespace, got %+v", ts)
+		return
+	}
+
+	// team-a is full until its task is acked
+	_, err = teamA.Enqueue(ctx, []groove.Task{{ID: "ns.a.3"}}, false)
+	if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Errorf("expected the team-a quota to be exceeded, got %v", err)
+		return
+	}
+
+	_, err = groove.New(srv.URL, groove.WithAPIKey("team-a-secret")).Enqueue(ctx, []groove.Task{{ID: "ns.a.3"}}, false)
+	if err == nil || !strings.Contains(err.Error(), "cannot access namespace default") {
+		t.Errorf("expected the team-a key to be kept out of the default namespace, got %v", err)
+		return
+	}
+
+	_, err = groove.New(srv.URL, groove.WithAPIKey("all-secret"), groove.WithNamespace("missing")).Stats(ctx, "", 1)
+	if err == nil || !strings.Contains(err.Error(), "namespace missing does not exist") {
+		t.Errorf("expected an unknown namespace to be rejected, got %v", err)
+		return
+	}
+
+	req, _ := http.NewRequest("POST", srv.URL+"/enqueue", strings.NewReader(`{"tasks":[{"id":"ns.a.3"}]}`))
+	req.Header.Set("Authorization", "Bearer team-a-secret")
+	req.Header.Set(groove.NamespaceHeader, "team-a")
+
+	httpRes, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	httpRes.Body.Close()
+
+	if httpRes.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected a quota rejection to be status 429, got %d", httpRes.StatusCode)
+		return
+	}
+
+	_, err = groove.New(srv.URL, groove.WithSignedRequests("all", "all-secret"), groove.WithNamespace("team-a")).Stats(ctx, "", 1)
+	if err != nil {
+		t.Errorf("expected a signed request to team-a to be accepted, got %v", err)
+		return
+	}
+
+	// A signed request cannot be replayed against another namespace
+	body := []byte(`{"desired_task_count": 1, "prefix": "ns.a", "timeout": 1000}`)
+	now := time.Now()
+	nonce := groove.NewNonce()
+
+	req, _ = http.NewRequest("POST", srv.URL+"/dequeue", bytes.NewReader(body))
+	req.Header.Set(groove.KeyIDHeader, "all")
+	req.Header.Set(groove.TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(groove.NonceHeader, nonce)
+	req.Header.Set(groove.NamespaceHeader, groove.DefaultNamespace)
+	req.Header.Set(groove.RequestSignatureHeader, groove.SignRequest("all-secret", "POST", "/dequeue", "team-a", now, nonce, body))
+
+	httpRes, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	httpRes.Body.Close()
+
+	if httpRes.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a request signed for team-a to be rejected in the default namespace, got %d", httpRes.StatusCode)
+		return
+	}
+
+	var buf bytes.Buffer
+
+	ns.Get("team-a").WriteMetrics(&buf, 1)
+
+	if !strings.Contains(buf.String(), `groove_tasks_pending{namespace="team-a",prefix="ns"}`) {
+		t.Errorf("expected team-a metrics to be labelled with the namespace, got\n%s", buf.String())
+	}
+}
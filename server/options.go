@@ -0,0 +1,68 @@
+package server
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Limits bound how much work a single request can ask for, and how much history is kept
+type Limits struct {
+	MaxEnqueueTasks     int   `json:"max_enqueue_tasks"`                // The most tasks enqueued in one request, including tasks enqueued alongside an ack
+	MaxDequeueTasks     int   `json:"max_dequeue_tasks"`                // The most tasks dequeued in one task set
+	MaxDeadLetters      int   `json:"max_dead_letters"`                 // The most dead letters kept, the oldest are dropped once it is reached
+	MaxTaskPayloadBytes int64 `json:"max_task_payload_bytes,omitempty"` // The largest json encoded data of a single task, zero is unlimited
+}
+
+// DefaultLimits are used for any limit that is not set
+var DefaultLimits = Limits{
+	MaxEnqueueTasks: 1000,
+	MaxDequeueTasks: 1000,
+	MaxDeadLetters:  10000,
+}
+
+// Option configures a GrooveMaster when it is created
+type Option func(g *GrooveMaster)
+
+// WithClock replaces the clock used for task timeouts, enqueue times, statistics, batch retention and webhook backoff
+func WithClock(clock Clock) Option {
+	return func(g *GrooveMaster) {
+		g.clock = clock
+	}
+}
+
+// WithStorage saves a snapshot of the queue to storage every interval, and when the GrooveMaster is closed.
+// Use Open to restore the queue from storage when starting up
+func WithStorage(storage Storage, interval time.Duration) Option {
+	return func(g *GrooveMaster) {
+		g.storage = storage
+		g.snapshotInterval = interval
+	}
+}
+
+// WithLimits replaces the default limits, limits that are left as zero keep their default
+func WithLimits(limits Limits) Option {
+	return func(g *GrooveMaster) {
+		if limits.MaxEnqueueTasks > 0 {
+			g.limits.MaxEnqueueTasks = limits.MaxEnqueueTasks
+		}
+
+		if limits.MaxDequeueTasks > 0 {
+			g.limits.MaxDequeueTasks = limits.MaxDequeueTasks
+		}
+
+		if limits.MaxDeadLetters > 0 {
+			g.limits.MaxDeadLetters = limits.MaxDeadLetters
+		}
+
+		if limits.MaxTaskPayloadBytes > 0 {
+			g.limits.MaxTaskPayloadBytes = limits.MaxTaskPayloadBytes
+		}
+	}
+}
+
+// WithLogger is the same as calling SetLogger once the GrooveMaster is created
+func WithLogger(logger *slog.Logger, sampleRate int) Option {
+	return func(g *GrooveMaster) {
+		g.setLogger(logger, sampleRate)
+	}
+}
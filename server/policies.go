@@ -0,0 +1,404 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// policyNode is a prefix in the policy tree, which mirrors the TaskContainer tree. Policies are kept apart from
+// the containers so that they outlive containers being pruned
+type policyNode struct {
+	policy   *groove.Policy
+	bucket   *tokenBucket // Set when the policy has a rate limit
+	children map[string]*policyNode
+}
+
+// policyTree holds the policy of every prefix that has one
+type policyTree struct {
+	root *policyNode
+}
+
+func newPolicyTree(policies map[string]groove.Policy) *policyTree {
+	t := &policyTree{root: &policyNode{}}
+
+	for prefix, p := range policies {
+		t.set(prefix, p)
+	}
+
+	return t
+}
+
+func (t *policyTree) set(prefix string, p groove.Policy) {
+	n := t.root
+
+	for _, part := range strings.Split(prefix, ".") {
+		if n.children == nil {
+			n.children = map[string]*policyNode{}
+		}
+
+		child, ok := n.children[part]
+		if !ok {
+			child = &policyNode{}
+			n.children[part] = child
+		}
+
+		n = child
+	}
+
+	n.policy = &p
+	n.bucket = nil
+
+	if p.RateLimit > 0 {
+		n.bucket = newTokenBucket(p.RateLimit)
+	}
+}
+
+// remove deletes the policy of a prefix, returning false if it did not have one
+func (t *policyTree) remove(prefix string) bool {
+	parts := strings.Split(prefix, ".")
+	path := t.path(parts)
+
+	if len(path) <= len(parts) || path[len(parts)].policy == nil {
+		return false
+	}
+
+	path[len(parts)].policy = nil
+	path[len(parts)].bucket = nil
+
+	// Prune nodes that no longer lead to a policy
+	for i := len(parts); i > 0 && path[i].policy == nil && len(path[i].children) == 0; i-- {
+		delete(path[i-1].children, parts[i-1])
+	}
+
+	return true
+}
+
+// get returns the policy set on a prefix itself
+func (t *policyTree) get(prefix string) *groove.Policy {
+	parts := strings.Split(prefix, ".")
+	path := t.path(parts)
+
+	if len(path) <= len(parts) || path[len(parts)].policy == nil {
+		return nil
+	}
+
+	p := *path[len(parts)].policy
+
+	return &p
+}
+
+// all returns every policy by prefix
+func (t *policyTree) all() map[string]groove.Policy {
+	policies := map[string]groove.Policy{}
+
+	var walk func(n *policyNode, prefix string)
+	walk = func(n *policyNode, prefix string) {
+		if n.policy != nil {
+			policies[prefix] = *n.policy
+		}
+
+		for part, child := range n.children {
+			if prefix != "" {
+				walk(child, prefix+"."+part)
+			} else {
+				walk(child, part)
+			}
+		}
+	}
+
+	walk(t.root, "")
+
+	return policies
+}
+
+// path returns the nodes from the root down to the prefix made of parts, stopping early at the first missing node
+func (t *policyTree) path(parts []string) []*policyNode {
+	path := []*policyNode{t.root}
+
+	n := t.root
+	for _, part := range parts {
+		if n = n.children[part]; n == nil {
+			break
+		}
+
+		path = append(path, n)
+	}
+
+	return path
+}
+
+// resolve applies the inherited settings of every policy above an id or prefix to base, closest last
+func (t *policyTree) resolve(id string, base groove.Policy) groove.Policy {
+	p := base
+
+	if id == "" {
+		return p
+	}
+
+	for _, n := range t.path(strings.Split(id, ".")) {
+		if n.policy == nil {
+			continue
+		}
+
+		if n.policy.RetryThreshold != nil {
+			p.RetryThreshold = n.policy.RetryThreshold
+		}
+
+		if n.policy.DequeueTimeout != nil {
+			p.DequeueTimeout = n.policy.DequeueTimeout
+		}
+
+		if n.policy.MaxLease != nil {
+			p.MaxLease = n.policy.MaxLease
+		}
+
+		if n.policy.MaxPayloadBytes != nil {
+			p.MaxPayloadBytes = n.policy.MaxPayloadBytes
+		}
+
+		if n.policy.DeadLetter != nil {
+			p.DeadLetter = n.policy.DeadLetter
+		}
+	}
+
+	return p
+}
+
+// child returns the node for a part below n, nil is a prefix without any policies below it
+func (n *policyNode) child(part string) *policyNode {
+	if n == nil {
+		return nil
+	}
+
+	return n.children[part]
+}
+
+// blocks checks if the policy of n stops any more tasks being dequeued from under container t
+func (n *policyNode) blocks(t *TaskContainer, now time.Time) bool {
+	if n == nil || n.policy == nil {
+		return false
+	}
+
+	if n.policy.Concurrency > 0 && t.locked >= n.policy.Concurrency {
+		return true
+	}
+
+	return n.bucket != nil && !n.bucket.ready(now)
+}
+
+// took records that a task was dequeued from under n
+func (n *policyNode) took(now time.Time) {
+	if n != nil && n.bucket != nil {
+		n.bucket.take(now)
+	}
+}
+
+// tokenBucket allows rate tasks per second, with bursts of up to a second's worth
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	return &tokenBucket{rate: rate, tokens: math.Max(rate, 1)}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.last.IsZero() && now.After(b.last) {
+		b.tokens = math.Min(math.Max(b.rate, 1), b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+
+	b.last = now
+}
+
+func (b *tokenBucket) ready(now time.Time) bool {
+	b.refill(now)
+
+	return b.tokens >= 1
+}
+
+func (b *tokenBucket) take(now time.Time) {
+	b.refill(now)
+
+	b.tokens--
+}
+
+// validatePolicy checks that a policy can be set on a prefix
+func validatePolicy(prefix string, p groove.Policy) error {
+	var errs []error
+
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("policy %s: "+format, append([]interface{}{prefix}, args...)...))
+		}
+	}
+
+	check(validPrefix(prefix), "not a valid prefix")
+	check(p.RetryThreshold == nil || *p.RetryThreshold >= 0, "retry_threshold cannot be negative")
+	check(p.DequeueTimeout == nil || *p.DequeueTimeout > 0, "dequeue_timeout must be greater than 0")
+	check(p.MaxLease == nil || *p.MaxLease >= 0, "max_lease cannot be negative")
+	check(p.MaxPayloadBytes == nil || *p.MaxPayloadBytes >= 0, "max_payload_bytes cannot be negative")
+	check(p.Concurrency >= 0, "concurrency cannot be negative")
+	check(p.RateLimit >= 0 && !math.IsInf(p.RateLimit, 0) && !math.IsNaN(p.RateLimit), "rate_limit cannot be negative or infinite")
+
+	return errors.Join(errs...)
+}
+
+// SetPolicy sets the policy of a prefix, replacing any policy it had. The change is kept in snapshots,
+// where it takes the place of any policy the config has for the prefix
+func (g *GrooveMaster) SetPolicy(prefix string, p groove.Policy) error {
+	if err := validatePolicy(prefix, p); err != nil {
+		return err
+	}
+
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	g.policies.set(prefix, p)
+	g.policyChanges[prefix] = &p
+
+	return nil
+}
+
+// RemovePolicy removes the policy of a prefix, returning false if it did not have one.
+// The removal is kept in snapshots, so that a policy from the config stays removed after a restart
+func (g *GrooveMaster) RemovePolicy(prefix string) bool {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	if !g.policies.remove(prefix) {
+		return false
+	}
+
+	g.policyChanges[prefix] = nil
+
+	return true
+}
+
+// Policies returns every policy by prefix
+func (g *GrooveMaster) Policies() map[string]groove.Policy {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	return g.policies.all()
+}
+
+// Policy returns the policy set on a prefix itself (nil if there is none), and the inherited settings
+// that apply to tasks under the prefix
+func (g *GrooveMaster) Policy(prefix string) (*groove.Policy, groove.Policy) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	return g.policies.get(prefix), g.policyFor(prefix)
+}
+
+// policyFor returns the inherited settings for a task id or prefix, starting from the defaults.
+// Every inherited setting apart from DeadLetter is set in the returned policy.
+// policyFor is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) policyFor(id string) groove.Policy {
+	p := g.policies.resolve(id, groove.Policy{
+		RetryThreshold:  groove.Ptr(g.defaults.RetryThreshold),
+		DequeueTimeout:  groove.Ptr(g.defaults.DequeueTimeout),
+		MaxLease:        groove.Ptr(groove.Duration(0)),
+		MaxPayloadBytes: groove.Ptr(g.limits.MaxTaskPayloadBytes),
+	})
+
+	// A policy can only lower the server's payload limit, never raise it
+	if limit := g.limits.MaxTaskPayloadBytes; limit > 0 && (*p.MaxPayloadBytes == 0 || *p.MaxPayloadBytes > limit) {
+		p.MaxPayloadBytes = groove.Ptr(limit)
+	}
+
+	return p
+}
+
+// maxLease returns the shortest max lease of the policies of tasks, zero if none of them have one.
+// maxLease is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) maxLease(taskIDs []string) time.Duration {
+	var lease time.Duration
+
+	for _, id := range taskIDs {
+		if l := time.Duration(*g.policyFor(id).MaxLease); l > 0 && (lease == 0 || l < lease) {
+			lease = l
+		}
+	}
+
+	return lease
+}
+
+// deadLettersEnabled checks if failed tasks with an id are kept as dead letters.
+// deadLettersEnabled is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) deadLettersEnabled(taskID string) bool {
+	p := g.policyFor(taskID)
+
+	return p.DeadLetter == nil || *p.DeadLetter
+}
+
+func (g *GrooveMaster) hListPolicies(c *gin.Context) {
+	if !g.authorize(c, groove.PermissionRead, "") {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "policies": g.Policies()})
+}
+
+func (g *GrooveMaster) hGetPolicy(c *gin.Context) {
+	prefix := c.Param("prefix")
+
+	if !g.authorize(c, groove.PermissionRead, prefix) {
+		return
+	}
+
+	policy, effective := g.Policy(prefix)
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "prefix": prefix, "policy": policy, "effective": effective})
+}
+
+func (g *GrooveMaster) hSetPolicy(c *gin.Context) {
+	prefix := c.Param("prefix")
+
+	var policy groove.Policy
+
+	err := c.ShouldBindJSON(&policy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !g.authorize(c, groove.PermissionAdmin, prefix) {
+		return
+	}
+
+	err = g.SetPolicy(prefix, policy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, effective := g.Policy(prefix)
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "prefix": prefix, "policy": policy, "effective": effective})
+}
+
+func (g *GrooveMaster) hRemovePolicy(c *gin.Context) {
+	prefix := c.Param("prefix")
+
+	if !g.authorize(c, groove.PermissionAdmin, prefix) {
+		return
+	}
+
+	if !g.RemovePolicy(prefix) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("%s does not have a policy", prefix)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
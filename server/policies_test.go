@@ -0,0 +1,286 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func TestPolicyTree(t *testing.T) {
+	off := false
+
+	tree := newPolicyTree(map[string]groove.Policy{
+		"a":     {RetryThreshold: groove.Ptr(2), MaxLease: groove.Ptr(groove.Duration(time.Minute))},
+		"a.b":   {RetryThreshold: groove.Ptr(4), DeadLetter: &off},
+		"a.b.c": {Concurrency: 1},
+		"a.z":   {RetryThreshold: groove.Ptr(0)},
+	})
+
+	p := tree.resolve("a.b.c.1", groove.Policy{RetryThreshold: groove.Ptr(1), DequeueTimeout: groove.Ptr(groove.Duration(time.Second))})
+
+	if *p.RetryThreshold != 4 || *p.MaxLease != groove.Duration(time.Minute) || *p.DequeueTimeout != groove.Duration(time.Second) || p.DeadLetter == nil || *p.DeadLetter {
+		t.Errorf("expected settings to be inherited from the closest policy, got %+v", p)
+		return
+	}
+
+	// Zero is a setting of its own, rather than a gap that the parent fills
+	if p := tree.resolve("a.z.1", groove.Policy{RetryThreshold: groove.Ptr(1)}); *p.RetryThreshold != 0 {
+		t.Errorf("expected a.z to override the retry threshold of a with zero, got %d", *p.RetryThreshold)
+		return
+	}
+
+	if p.Concurrency != 0 {
+		t.Errorf("expected concurrency not to be inherited, got %d", p.Concurrency)
+		return
+	}
+
+	if tree.remove("a.b.c.d") || !tree.remove("a.b.c") || tree.get("a.b.c") != nil {
+		t.Error("expected only the a.b.c policy to be removed")
+		return
+	}
+
+	if !tree.remove("a.b") || !tree.remove("a.z") || len(tree.root.children["a"].children) != 0 {
+		t.Error("expected nodes without policies to be pruned")
+		return
+	}
+
+	if all := tree.all(); len(all) != 1 || *all["a"].RetryThreshold != 2 {
+		t.Errorf("expected only the a policy to be left, got %+v", all)
+	}
+}
+
+func TestGrooveMaster_PolicyLimits(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+
+	g := New(WithClock(clock))
+	defer g.Close()
+
+	err := g.SetPolicy("conc", groove.Policy{Concurrency: 2, MaxLease: groove.Ptr(groove.Duration(time.Minute))})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := g.SetPolicy("rate", groove.Policy{RateLimit: 2}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, bad := range []groove.Policy{{Concurrency: -1}, {DequeueTimeout: groove.Ptr(groove.Duration(0))}} {
+		if err := g.SetPolicy("bad", bad); err == nil {
+			t.Errorf("expected %+v to be rejected", bad)
+			return
+		}
+	}
+
+	g.Enqueue([]groove.Task{{ID: "conc.a.1"}, {ID: "conc.b.1"}, {ID: "conc.c.1"}})
+
+	ts := g.Dequeue(10, "conc", time.Hour)
+	if ts == nil || len(ts.Tasks) != 2 {
+		t.Errorf("expected the concurrency policy to allow 2 tasks, got %+v", ts)
+		return
+	}
+
+	if timeout := g.TaskSets(1)[0].TimeoutAt.Sub(clock.Now()); timeout != time.Minute || ts.Timeout != 60000 {
+		t.Errorf("expected the lease to be capped at a minute, got %s (reported as %dms)", timeout, ts.Timeout)
+		return
+	}
+
+	if granted, err := g.Extend(ts.ID, time.Hour); err != nil || granted != time.Minute {
+		t.Errorf("expected an extend to be capped at a minute, got %s (%v)", granted, err)
+		return
+	}
+
+	// The policy of a parent prefix applies when dequeuing below it
+	if ts := g.Dequeue(10, "conc.c", time.Hour); ts != nil {
+		t.Errorf("expected no tasks while conc is at its concurrency, got %+v", ts.Tasks)
+		return
+	}
+
+	if err := g.AckTask(ts.ID, ts.Tasks[0].ID, nil); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if ts := g.Dequeue(10, "conc", time.Hour); ts == nil || len(ts.Tasks) != 1 {
+		t.Errorf("expected a task once one was acked, got %+v", ts)
+		return
+	}
+
+	for i := 0; i < 5; i++ {
+		g.Enqueue([]groove.Task{{ID: "rate." + string(rune('a'+i)) + ".1"}})
+	}
+
+	dequeued := func() int {
+		n := 0
+
+		for ts := g.Dequeue(1, "rate", time.Hour); ts != nil; ts = g.Dequeue(1, "rate", time.Hour) {
+			n += len(ts.Tasks)
+		}
+
+		return n
+	}
+
+	if n := dequeued(); n != 2 {
+		t.Errorf("expected the rate limit to allow a burst of 2 tasks, got %d", n)
+		return
+	}
+
+	clock.advance(time.Second)
+
+	if n := dequeued(); n != 2 {
+		t.Errorf("expected the rate limit to allow 2 more tasks after a second, got %d", n)
+	}
+}
+
+func TestGrooveMaster_PolicyDeadLetter(t *testing.T) {
+	g := New()
+	defer g.Close()
+
+	off := false
+
+	if err := g.SetPolicy("nodlq", groove.Policy{DeadLetter: &off}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	g.Enqueue([]groove.Task{{ID: "nodlq.a.1"}, {ID: "dlq.a.1"}})
+
+	for _, prefix := range []string{"nodlq", "dlq"} {
+		ts := g.Dequeue(1, prefix, time.Minute)
+		if ts == nil {
+			t.Errorf("expected a task under %s", prefix)
+			return
+		}
+
+		if err := g.Nack(ts.ID, "failed"); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	if dls := g.DeadLetters("", 10); len(dls) != 1 || dls[0].Task.ID != "dlq.a.1" {
+		t.Errorf("expected only dlq.a.1 to be dead lettered, got %+v", dls)
+	}
+}
+
+func TestGrooveMaster_PolicyAPI(t *testing.T) {
+	g := New()
+	defer g.Close()
+
+	srv := httptest.NewServer(g.Handler())
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := groove.New(srv.URL)
+
+	_, err := client.SetPolicy(ctx, "api.jobs", groove.Policy{RetryThreshold: groove.Ptr(3), Concurrency: 4})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	res, err := client.Policy(ctx, "api.jobs.images")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if res.Policy != nil || *res.Effective.RetryThreshold != 3 || res.Effective.Concurrency != 0 {
+		t.Errorf("expected api.jobs.images to inherit only the retry threshold, got %+v", res)
+		return
+	}
+
+	if _, err := client.SetPolicy(ctx, "api..jobs", groove.Policy{}); err == nil {
+		t.Error("expected an invalid prefix to be rejected")
+		return
+	}
+
+	if _, err := client.RemovePolicy(ctx, "api.jobs"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	list, err := client.Policies(ctx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(list.Policies) != 0 {
+		t.Errorf("expected no policies to be left, got %+v", list.Policies)
+	}
+}
+
+func TestGrooveMaster_PolicyStorage(t *testing.T) {
+	storage := NewFileStorage(filepath.Join(t.TempDir(), "groove.json"))
+
+	cfg := DefaultConfig()
+	cfg.Policies = map[string]groove.Policy{
+		"storage.a": {RetryThreshold: groove.Ptr(1)},
+		"storage.b": {RetryThreshold: groove.Ptr(2)},
+		"storage.c": {RetryThreshold: groove.Ptr(3)},
+	}
+
+	g, err := Open(WithConfig(cfg), WithStorage(storage, time.Hour))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = g.SetPolicy("storage.a", groove.Policy{Concurrency: 1})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	g.RemovePolicy("storage.b")
+
+	err = g.Close()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// The config changes while groove is stopped
+	cfg.Policies = map[string]groove.Policy{
+		"storage.a": {RetryThreshold: groove.Ptr(1)},
+		"storage.b": {RetryThreshold: groove.Ptr(2)},
+		"storage.c": {RetryThreshold: groove.Ptr(5)},
+		"storage.d": {RetryThreshold: groove.Ptr(4)},
+	}
+
+	g, err = Open(WithConfig(cfg), WithStorage(storage, time.Hour))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	defer g.Close()
+
+	policies := g.Policies()
+
+	if p, ok := policies["storage.a"]; !ok || p.Concurrency != 1 || p.RetryThreshold != nil {
+		t.Errorf("expected the policy set on storage.a to replace the config, got %+v", p)
+	}
+
+	if _, ok := policies["storage.b"]; ok {
+		t.Error("expected storage.b to stay removed")
+	}
+
+	if p, ok := policies["storage.c"]; !ok || p.RetryThreshold == nil || *p.RetryThreshold != 5 {
+		t.Errorf("expected the changed config policy of storage.c to be used, got %+v", p)
+	}
+
+	if p, ok := policies["storage.d"]; !ok || p.RetryThreshold == nil || *p.RetryThreshold != 4 {
+		t.Errorf("expected the new config policy of storage.d to be used, got %+v", p)
+	}
+
+	if s := g.Snapshot(); len(s.Policies) != 1 || len(s.RemovedPolicies) != 1 {
+		t.Errorf("expected only the changed policies to be kept in snapshots, got %+v and %v", s.Policies, s.RemovedPolicies)
+	}
+}
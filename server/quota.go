@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// ErrQuotaExceeded is returned (wrapped) when an operation would take a GrooveMaster over its quota
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// WithQuota limits what the GrooveMaster will accept. Enqueues and dequeues that would exceed the quota fail with ErrQuotaExceeded
+func WithQuota(quota groove.Quota) Option {
+	return func(g *GrooveMaster) {
+		g.quota = quota
+	}
+}
+
+// QuotaUsage reports the quota and how much of it is used
+func (g *GrooveMaster) QuotaUsage() groove.QuotaUsage {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	return groove.QuotaUsage{
+		Namespace:        g.namespace,
+		Quota:            g.quota,
+		PendingTasks:     g.RootContainer.pending + g.RootContainer.locked,
+		PayloadBytes:     g.RootContainer.payload,
+		InFlightTaskSets: len(g.TaskSetLogs),
+	}
+}
+
+func (g *GrooveMaster) hQuota(c *gin.Context) {
+	if !g.authorize(c, groove.PermissionRead, "") {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "quota": g.QuotaUsage()})
+}
+
+// checkEnqueueQuota returns an error if storing tasks would exceed the quota. The tasks must already have their payload sizes set.
+// checkEnqueueQuota is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) checkEnqueueQuota(tasks []groove.Task) error {
+	q := g.quota
+
+	if q.MaxPendingTasks > 0 {
+		stored := g.RootContainer.pending + g.RootContainer.locked
+
+		if stored+len(tasks) > q.MaxPendingTasks {
+			return fmt.Errorf("%w: %d tasks are stored, the limit is %d", ErrQuotaExceeded, stored, q.MaxPendingTasks)
+		}
+	}
+
+	if q.MaxPayloadBytes > 0 {
+		total := g.RootContainer.payload
+
+		for _, t := range tasks {
+			total += t.PayloadSize
+		}
+
+		if total > q.MaxPayloadBytes {
+			return fmt.Errorf("%w: storing the tasks would use %d payload bytes, the limit is %d", ErrQuotaExceeded, total, q.MaxPayloadBytes)
+		}
+	}
+
+	return nil
+}
+
+// checkDequeueQuota returns an error if another task set cannot be dequeued
+// checkDequeueQuota is not safe to be called on it's own. The caller must ensure thread safety
+func (g *GrooveMaster) checkDequeueQuota() error {
+	if g.quota.MaxInFlightTaskSets > 0 && len(g.TaskSetLogs) >= g.quota.MaxInFlightTaskSets {
+		return fmt.Errorf("%w: %d task sets are in flight, the limit is %d", ErrQuotaExceeded, len(g.TaskSetLogs), g.quota.MaxInFlightTaskSets)
+	}
+
+	return nil
+}
+
+// payloadSize is the size of the json encoded data of a task
+func payloadSize(t groove.Task) int64 {
+	if t.Data == nil {
+		return 0
+	}
+
+	b, err := json.Marshal(t.Data)
+	if err != nil {
+		return 0
+	}
+
+	return int64(len(b))
+}
+
+// addPayload adjusts the payload counter of this container and every container above it
+func (t *TaskContainer) addPayload(bytes int64) {
+	for n := t; n != nil; n = n.Parent {
+		n.payload += bytes
+	}
+}
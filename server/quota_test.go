@@ -0,0 +1,169 @@
+package server
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func TestGrooveMaster_Quota(t *testing.T) {
+	g := New(WithQuota(groove.Quota{MaxPendingTasks: 3, MaxPayloadBytes: 20, MaxInFlightTaskSets: 1}))
+	defer g.Close()
+
+	err := g.Enqueue([]groove.Task{{ID: "quota.a.1", Data: "0123456789"}, {ID: "quota.a.2"}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// "0123456789" is 12 bytes once encoded, another would go over the payload limit
+	err = g.Enqueue([]groove.Task{{ID: "quota.a.3", Data: "0123456789"}})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected the payload quota to be exceeded, got %v", err)
+		return
+	}
+
+	err = g.Enqueue([]groove.Task{{ID: "quota.a.3"}, {ID: "quota.a.4"}})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected the pending task quota to be exceeded, got %v", err)
+		return
+	}
+
+	usage := g.QuotaUsage()
+	if usage.PendingTasks != 2 || usage.PayloadBytes != 12 {
+		t.Errorf("expected 2 pending tasks using 12 bytes, got %+v", usage)
+		return
+	}
+
+	ts := g.Dequeue(1, "quota.a", time.Minute)
+	if ts == nil {
+		t.Error("expected a task set")
+		return
+	}
+
+	if _, err := g.dequeue(1, "quota.a", time.Minute, ""); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected the in flight task set quota to be exceeded, got %v", err)
+		return
+	}
+
+	// Acking enqueues are checked before the task set is acked
+	err = g.AckAndEnqueue(ts.ID, nil, []groove.Task{{ID: "quota.b.1"}, {ID: "quota.b.2"}})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected the pending task quota to be exceeded, got %v", err)
+		return
+	}
+
+	err = g.Ack(ts.ID, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	usage = g.QuotaUsage()
+	if usage.PendingTasks != 1 || usage.PayloadBytes != 0 || usage.InFlightTaskSets != 0 {
+		t.Errorf("expected the finished task to free its quota, got %+v", usage)
+		return
+	}
+
+	err = g.Enqueue([]groove.Task{{ID: "quota.a.3", Data: "0123456789"}})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGrooveMaster_QuotaRestore(t *testing.T) {
+	storage := NewFileStorage(filepath.Join(t.TempDir(), "groove.json"))
+	quota := groove.Quota{MaxPayloadBytes: 20}
+
+	g, err := Open(WithQuota(quota), WithStorage(storage, time.Hour))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = g.Enqueue([]groove.Task{{ID: "quota.a.1", Data: "0123456789"}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = g.Close()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	g, err = Open(WithQuota(quota), WithStorage(storage, time.Hour))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	defer g.Close()
+
+	// Restored tasks count towards the quota as they did before
+	if usage := g.QuotaUsage(); usage.PayloadBytes != 12 {
+		t.Errorf("expected the restored task to use 12 bytes, got %+v", usage)
+		return
+	}
+
+	err = g.Enqueue([]groove.Task{{ID: "quota.a.2", Data: "0123456789"}})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected the payload quota to be exceeded, got %v", err)
+	}
+}
+
+func TestGrooveMaster_QuotaRejectsWholeOperation(t *testing.T) {
+	g := New(WithQuota(groove.Quota{MaxPendingTasks: 1}))
+	defer g.Close()
+
+	// A batch is only made once its tasks are admitted, otherwise it would never complete
+	err := g.EnqueueBatch("over", nil, []groove.Task{{ID: "quota.batch.1"}, {ID: "quota.batch.2"}})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected the pending task quota to be exceeded, got %v", err)
+		return
+	}
+
+	if b, ok := g.Batch("over"); ok {
+		t.Errorf("expected no batch to be made, got %+v", b)
+		return
+	}
+
+	err = g.Enqueue([]groove.Task{{ID: "quota.a.1"}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ts := g.Dequeue(1, "quota.a", time.Minute)
+	if ts == nil {
+		t.Error("expected a task set")
+		return
+	}
+
+	err = g.Nack(ts.ID, "failed")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = g.Enqueue([]groove.Task{{ID: "quota.b.1"}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Dead letters that cannot be retried are kept
+	_, err = g.RetryDeadLetters("quota.a", nil)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected the pending task quota to be exceeded, got %v", err)
+		return
+	}
+
+	if dls := g.DeadLetters("", 100); len(dls) != 1 || dls[0].Task.ID != "quota.a.1" {
+		t.Errorf("expected quota.a.1 to still be dead lettered, got %+v", dls)
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// Handler returns an http.Handler serving the groove http api, with request logging and tracing
+func (g *GrooveMaster) Handler() http.Handler {
+	g.mx.Lock()
+	logger, sampleRate := g.logger, g.sampleRate
+	g.mx.Unlock()
+
+	r := gin.New()
+
+	r.Use(gin.Recovery(), RequestLogger(logger, sampleRate), TraceMiddleware)
+
+	g.RegisterRoutes(r)
+
+	return r
+}
+
+// RegisterRoutes adds the groove http api to a router, for serving groove alongside other routes
+func (g *GrooveMaster) RegisterRoutes(router gin.IRouter) {
+	registerHealth(router, func() []*GrooveMaster {
+		return []*GrooveMaster{g}
+	})
+
+	registerRoutes(router, func(c *gin.Context) *GrooveMaster {
+		return g
+	})
+}
+
+// registerRoutes adds the groove http api to a router, using resolve to find the GrooveMaster that a request acts on.
+// resolve aborts the request and returns nil if there is no such GrooveMaster
+func registerRoutes(router gin.IRouter, resolve func(c *gin.Context) *GrooveMaster) {
+	on := func(h func(g *GrooveMaster, c *gin.Context)) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			if g := resolve(c); g != nil {
+				h(g, c)
+			}
+		}
+	}
+
+	// The ui page itself is public, it asks for an api key when the api needs one
+	router.GET("/ui", hUI)
+
+	r := router.Group("", on((*GrooveMaster).authenticate))
+
+	r.POST("/dequeue", on((*GrooveMaster).hDequeue))
+	r.POST("/enqueue", on((*GrooveMaster).hEnqueue))
+	r.POST("/ack", on((*GrooveMaster).hAck))
+	r.POST("/nack", on((*GrooveMaster).hNack))
+	r.POST("/extend", on((*GrooveMaster).hExtend))
+
+	r.GET("/batches/:id", on((*GrooveMaster).hGetBatch))
+
+	r.GET("/webhooks", on((*GrooveMaster).hListWebhooks))
+	r.POST("/webhooks", on((*GrooveMaster).hAddWebhook))
+	r.DELETE("/webhooks/:id", on((*GrooveMaster).hRemoveWebhook))
+
+	r.GET("/events", on((*GrooveMaster).hEvents))
+	r.GET("/metrics", on((*GrooveMaster).hMetrics))
+	r.GET("/stats", on((*GrooveMaster).hStats))
+	r.GET("/quota", on((*GrooveMaster).hQuota))
+
+	r.GET("/browse/prefixes", on((*GrooveMaster).hBrowsePrefixes))
+	r.GET("/browse/tasks", on((*GrooveMaster).hBrowseTasks))
+
+	r.GET("/paused", on((*GrooveMaster).hListPaused))
+	r.POST("/pause", on((*GrooveMaster).hPause))
+	r.POST("/resume", on((*GrooveMaster).hResume))
+	r.POST("/cancel", on((*GrooveMaster).hCancel))
+	r.POST("/purge", on((*GrooveMaster).hPurge))
+	r.GET("/tasksets", on((*GrooveMaster).hListTaskSets))
+
+	r.GET("/dlq", on((*GrooveMaster).hListDeadLetters))
+	r.POST("/dlq/retry", on((*GrooveMaster).hRetryDeadLetters))
+	r.POST("/dlq/purge", on((*GrooveMaster).hPurgeDeadLetters))
+
+	r.GET("/policies", on((*GrooveMaster).hListPolicies))
+	r.GET("/policies/:prefix", on((*GrooveMaster).hGetPolicy))
+	r.PUT("/policies/:prefix", on((*GrooveMaster).hSetPolicy))
+	r.DELETE("/policies/:prefix", on((*GrooveMaster).hRemovePolicy))
+
+	r.GET("/config", on((*GrooveMaster).hConfig))
+	r.GET("/status", on((*GrooveMaster).hStatus))
+	r.GET("/data", on((*GrooveMaster).hData))
+}
+
+func (g *GrooveMaster) hStatus(c *gin.Context) {
+	if !g.authorize(c, groove.PermissionRead, "") {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": g.RootContainer.String()})
+}
+
+func (g *GrooveMaster) hData(c *gin.Context) {
+	if !g.authorize(c, groove.PermissionRead, "") {
+		return
+	}
+
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	c.JSON(http.StatusOK, g.RootContainer)
+}
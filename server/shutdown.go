@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ErrDraining is returned (wrapped) by operations that are refused because the GrooveMaster is shutting down
+var ErrDraining = errors.New("groove is shutting down")
+
+// drainPollInterval is how often Drain checks if every task set has finished
+const drainPollInterval = 50 * time.Millisecond
+
+// StopDequeues makes every later dequeue fail with ErrDraining, so no more tasks are handed out.
+// Task sets already dequeued can still be acked, nacked and extended
+func (g *GrooveMaster) StopDequeues() {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	if !g.draining {
+		g.draining = true
+		g.logger.Info("groove is draining, dequeues are stopped", slog.String("namespace", g.namespace))
+	}
+}
+
+// Draining reports if dequeues have been stopped
+func (g *GrooveMaster) Draining() bool {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	return g.draining
+}
+
+// Drain prepares the GrooveMaster to be closed. It stops dequeues, then waits until every task set
+// has finished or ctx is done. Anyone still waiting on enqueued tasks is then released, their tasks stay queued.
+// An error is returned if task sets were still in flight when ctx was done, their tasks are kept in the
+// next snapshot so that they are handed out again once groove restarts
+func (g *GrooveMaster) Drain(ctx context.Context) error {
+	g.StopDequeues()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	var err error
+
+	for {
+		g.mx.Lock()
+		inFlight := len(g.TaskSetLogs)
+		g.mx.Unlock()
+
+		if inFlight == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			err = fmt.Errorf("%d task sets were still in flight: %w", inFlight, ctx.Err())
+		case <-ticker.C:
+			continue
+		}
+
+		break
+	}
+
+	g.releaseWaits()
+
+	return err
+}
+
+// releaseWaits closes every wait, so that requests waiting on tasks return
+func (g *GrooveMaster) releaseWaits() {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	for id, waits := range g.Waits {
+		for _, w := range waits {
+			close(w)
+		}
+
+		delete(g.Waits, id)
+	}
+}
@@ -0,0 +1,734 @@
+package server
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+var (
+	simSeed  = flag.Int64("sim.seed", 0, "run the simulation with only this seed, to reproduce a failure")
+	simSeeds = flag.Int("sim.seeds", 25, "how many seeds the simulation runs when no seed is given")
+	simSteps = flag.Int("sim.steps", 400, "how many steps each simulation takes before draining the queue")
+)
+
+// simContainers are where the simulation puts tasks, nested so that prefixes cover more than one container
+var simContainers = []string{"sim.a", "sim.b", "sim.b.c", "sim.b.d", "sim.e.f.g"}
+
+// simPrefixes are what simulated workers dequeue from
+var simPrefixes = []string{"sim", "sim.a", "sim.b", "sim.b.c", "sim.e"}
+
+// simTask is what the simulation expects to have happened to a task
+type simTask struct {
+	id        string
+	container string
+	threshold int
+	retries   int
+
+	locked   bool // Set while the task is in a task set
+	done     bool
+	received bool // Set once the wait for the task has been resolved
+
+	succeeded bool
+	wait      chan groove.Task // Nil unless the task was enqueued with EnqueueAndWait
+}
+
+// simSet is a task set held by a simulated worker
+type simSet struct {
+	id        string
+	taskIDs   []string
+	timeoutAt time.Time
+	crashed   bool // The worker has gone away, so the task set can only time out
+	order     int  // When the task set was dequeued, task set ids are random so they cannot be used to pick one repeatably
+}
+
+// simulation drives a GrooveMaster with random work from a seed, keeping a model of what it should contain
+type simulation struct {
+	t     *testing.T
+	seed  int64
+	rng   *rand.Rand
+	clock *testClock
+	g     *GrooveMaster
+
+	tasks   map[string]*simTask
+	queues  map[string][]string // Pending task ids in each container, in the order they should be dequeued
+	sets    map[string]*simSet
+	nextID  int
+	nextSet int
+
+	history []string // The most recent steps, shown when an invariant fails
+}
+
+func TestSimulation(t *testing.T) {
+	seeds := make([]int64, *simSeeds)
+	for i := range seeds {
+		seeds[i] = int64(i + 1)
+	}
+
+	if *simSeed != 0 {
+		seeds = []int64{*simSeed}
+	}
+
+	for _, seed := range seeds {
+		seed := seed
+
+		t.Run(fmt.Sprintf("seed=%d", seed), func(t *testing.T) {
+			s := newSimulation(t, seed)
+			defer s.g.Close()
+
+			for i := 0; i < *simSteps; i++ {
+				if !s.step() || !s.check() {
+					return
+				}
+			}
+
+			s.drain()
+		})
+	}
+}
+
+func newSimulation(t *testing.T, seed int64) *simulation {
+	clock := &testClock{now: time.Unix(0, 0)}
+
+	return &simulation{
+		t:      t,
+		seed:   seed,
+		rng:    rand.New(rand.NewSource(seed)),
+		clock:  clock,
+		g:      New(WithClock(clock)),
+		tasks:  map[string]*simTask{},
+		queues: map[string][]string{},
+		sets:   map[string]*simSet{},
+	}
+}
+
+// fail reports a broken invariant along with the steps leading up to it and how to reproduce it
+func (s *simulation) fail(format string, args ...interface{}) bool {
+	s.t.Helper()
+
+	s.t.Errorf("%s\nlast steps:\n  %s\nreproduce with: go test ./server -run TestSimulation -sim.seed=%d -sim.steps=%d",
+		fmt.Sprintf(format, args...), strings.Join(s.history, "\n  "), s.seed, *simSteps)
+
+	return false
+}
+
+func (s *simulation) record(format string, args ...interface{}) {
+	s.history = append(s.history, fmt.Sprintf(format, args...))
+
+	if len(s.history) > 20 {
+		s.history = s.history[1:]
+	}
+}
+
+// step takes one random action, returning false if the GrooveMaster did not behave as expected
+func (s *simulation) step() bool {
+	n := s.rng.Intn(100)
+
+	switch {
+	case n < 22:
+		return s.enqueue()
+	case n < 44:
+		return s.dequeue()
+	case n < 56:
+		return s.finish(s.liveSet(), true)
+	case n < 64:
+		return s.finish(s.liveSet(), false)
+	case n < 71:
+		return s.finishTask(s.liveSet(), true)
+	case n < 78:
+		return s.finishTask(s.liveSet(), false)
+	case n < 82:
+		return s.extend(s.liveSet())
+	case n < 85:
+		return s.crash(s.liveSet())
+	case n < 88:
+		return s.cancel()
+	case n < 91:
+		return s.stale()
+	default:
+		return s.advance(time.Duration(s.rng.Intn(3000)) * time.Millisecond)
+	}
+}
+
+func (s *simulation) enqueue() bool {
+	tasks := make([]groove.Task, 1+s.rng.Intn(3))
+
+	for i := range tasks {
+		s.nextID++
+
+		container := simContainers[s.rng.Intn(len(simContainers))]
+		tasks[i] = groove.Task{ID: fmt.Sprintf("%s.%d", container, s.nextID), RetryThreshold: 1 + s.rng.Intn(3)}
+	}
+
+	wait := s.rng.Intn(3) == 0
+
+	var waits []chan groove.Task
+	var err error
+
+	if wait {
+		waits, err = s.g.EnqueueAndWait(tasks)
+	} else {
+		err = s.g.Enqueue(tasks)
+	}
+
+	s.record("enqueue %d tasks, wait %t: %v", len(tasks), wait, err)
+
+	if err != nil {
+		return s.fail("could not enqueue: %s", err)
+	}
+
+	for i, task := range tasks {
+		st := &simTask{id: task.ID, container: containerOf(task.ID), threshold: task.RetryThreshold}
+
+		if wait {
+			st.wait = waits[i]
+		}
+
+		s.tasks[st.id] = st
+		s.queues[st.container] = append(s.queues[st.container], st.id)
+	}
+
+	return true
+}
+
+func (s *simulation) dequeue() bool {
+	prefix := simPrefixes[s.rng.Intn(len(simPrefixes))]
+	desired := 1 + s.rng.Intn(4)
+	timeout := time.Duration(1+s.rng.Intn(5)) * time.Second
+
+	ts := s.g.Dequeue(desired, prefix, timeout)
+
+	if ts == nil {
+		s.record("dequeue %d from %s: nothing", desired, prefix)
+
+		for container, queue := range s.queues {
+			if len(queue) > 0 && underPrefix(container, prefix) && !s.containerLocked(container) {
+				return s.fail("nothing was dequeued from %s, but %s has %d tasks and is not locked", prefix, container, len(queue))
+			}
+		}
+
+		return true
+	}
+
+	s.record("dequeue %d from %s: %s with %d tasks", desired, prefix, ts.ID, len(ts.Tasks))
+
+	if len(ts.Tasks) == 0 || len(ts.Tasks) > desired {
+		return s.fail("asked for %d tasks, got %d", desired, len(ts.Tasks))
+	}
+
+	s.nextSet++
+	set := &simSet{id: ts.ID, timeoutAt: s.clock.Now().Add(timeout), order: s.nextSet}
+
+	for _, task := range ts.Tasks {
+		st, ok := s.tasks[task.ID]
+		if !ok || st.done || st.locked {
+			return s.fail("dequeued %s, which was not pending", task.ID)
+		}
+
+		if !underPrefix(st.container, prefix) {
+			return s.fail("dequeued %s from prefix %s", task.ID, prefix)
+		}
+
+		if s.containerLocked(st.container) {
+			return s.fail("dequeued %s while another task in %s was locked", task.ID, st.container)
+		}
+
+		queue := s.queues[st.container]
+		if queue[0] != task.ID {
+			return s.fail("dequeued %s out of order, %s was first in %s", task.ID, queue[0], st.container)
+		}
+
+		if task.RetryCount != st.retries {
+			return s.fail("dequeued %s with %d retries, expected %d", task.ID, task.RetryCount, st.retries)
+		}
+
+		s.queues[st.container] = queue[1:]
+		st.locked = true
+		set.taskIDs = append(set.taskIDs, task.ID)
+	}
+
+	s.sets[set.id] = set
+
+	return true
+}
+
+// finish acks or nacks a whole task set
+func (s *simulation) finish(set *simSet, ack bool) bool {
+	if set == nil {
+		return true
+	}
+
+	var err error
+
+	if ack {
+		err = s.g.Ack(set.id, "done")
+	} else {
+		err = s.g.Nack(set.id, "failed")
+	}
+
+	s.record("ack %s %t: %v", set.id, ack, err)
+
+	if err != nil {
+		return s.fail("could not finish %s: %s", set.id, err)
+	}
+
+	for _, id := range set.taskIDs {
+		s.finished(s.tasks[id], ack)
+	}
+
+	delete(s.sets, set.id)
+
+	return true
+}
+
+// finishTask acks or nacks a single task in a task set
+func (s *simulation) finishTask(set *simSet, ack bool) bool {
+	if set == nil {
+		return true
+	}
+
+	i := s.rng.Intn(len(set.taskIDs))
+	id := set.taskIDs[i]
+
+	var err error
+
+	if ack {
+		err = s.g.AckTask(set.id, id, "done")
+	} else {
+		err = s.g.NackTask(set.id, id, "failed")
+	}
+
+	s.record("ack task %s in %s %t: %v", id, set.id, ack, err)
+
+	if err != nil {
+		return s.fail("could not finish %s in %s: %s", id, set.id, err)
+	}
+
+	s.finished(s.tasks[id], ack)
+
+	set.taskIDs = append(append([]string(nil), set.taskIDs[:i]...), set.taskIDs[i+1:]...)
+	if len(set.taskIDs) == 0 {
+		delete(s.sets, set.id)
+	}
+
+	return true
+}
+
+// finished updates the model for a locked task that was acked, nacked or timed out
+func (s *simulation) finished(st *simTask, succeeded bool) {
+	st.locked = false
+
+	if succeeded {
+		st.done = true
+		st.succeeded = true
+
+		return
+	}
+
+	st.retries++
+
+	if st.retries > st.threshold {
+		st.done = true
+		return
+	}
+
+	// Failed tasks go back to the front of their container
+	s.queues[st.container] = append([]string{st.id}, s.queues[st.container]...)
+}
+
+func (s *simulation) extend(set *simSet) bool {
+	if set == nil {
+		return true
+	}
+
+	timeout := time.Duration(1+s.rng.Intn(5)) * time.Second
+
+	granted, err := s.g.Extend(set.id, timeout)
+
+	s.record("extend %s by %s: %v", set.id, timeout, err)
+
+	if err != nil {
+		return s.fail("could not extend %s: %s", set.id, err)
+	}
+
+	if granted != timeout {
+		return s.fail("expected %s to be extended by %s, got %s", set.id, timeout, granted)
+	}
+
+	set.timeoutAt = s.clock.Now().Add(granted)
+
+	return true
+}
+
+// crash forgets a task set, as if its worker had died, so that it can only time out
+func (s *simulation) crash(set *simSet) bool {
+	if set == nil {
+		return true
+	}
+
+	s.record("crash %s", set.id)
+	set.crashed = true
+
+	return true
+}
+
+// cancel removes a pending task, or tries to cancel a locked one which must fail
+func (s *simulation) cancel() bool {
+	ids := s.unfinished()
+	if len(ids) == 0 {
+		return true
+	}
+
+	st := s.tasks[ids[s.rng.Intn(len(ids))]]
+
+	err := s.g.Cancel(st.id)
+
+	s.record("cancel %s: %v", st.id, err)
+
+	if st.locked {
+		if err == nil {
+			return s.fail("cancelled %s while it was locked", st.id)
+		}
+
+		return true
+	}
+
+	if err != nil {
+		return s.fail("could not cancel %s: %s", st.id, err)
+	}
+
+	st.done = true
+
+	queue := s.queues[st.container]
+	for i, id := range queue {
+		if id == st.id {
+			s.queues[st.container] = append(append([]string(nil), queue[:i]...), queue[i+1:]...)
+			break
+		}
+	}
+
+	return true
+}
+
+// stale acks or nacks a task set that has already finished, which must fail without changing anything
+func (s *simulation) stale() bool {
+	id := fmt.Sprintf("missing-%d", s.rng.Intn(10))
+
+	var err error
+
+	switch s.rng.Intn(4) {
+	case 0:
+		err = s.g.Ack(id, nil)
+	case 1:
+		err = s.g.Nack(id, nil)
+	case 2:
+		err = s.g.AckTask(id, "sim.a.1", nil)
+	default:
+		_, err = s.g.Extend(id, time.Second)
+	}
+
+	s.record("stale %s: %v", id, err)
+
+	if err == nil {
+		return s.fail("finishing task set %s that does not exist succeeded", id)
+	}
+
+	return true
+}
+
+// advance moves the clock forward and times out any task sets that expired
+func (s *simulation) advance(d time.Duration) bool {
+	s.clock.advance(d)
+
+	// The background loop is woken by the clock, expiring here as well makes the result of the step certain
+	s.g.mx.Lock()
+	s.g.expireLeases()
+	s.g.mx.Unlock()
+
+	now := s.clock.Now()
+
+	var expired []*simSet
+
+	for _, set := range s.sets {
+		if now.After(set.timeoutAt) {
+			expired = append(expired, set)
+		}
+	}
+
+	s.record("advance %s, %d task sets expire", d, len(expired))
+
+	for _, set := range expired {
+		for _, id := range set.taskIDs {
+			s.finished(s.tasks[id], false)
+		}
+
+		delete(s.sets, set.id)
+	}
+
+	return true
+}
+
+// drain finishes every task, then checks that nothing was left behind and every wait was resolved
+func (s *simulation) drain() {
+	for i := 0; len(s.unfinished()) > 0; i++ {
+		if i > 10000 {
+			s.fail("the queue did not drain, %d tasks are unfinished", len(s.unfinished()))
+			return
+		}
+
+		ok := true
+
+		if set := s.liveSet(); set != nil {
+			ok = s.finish(set, s.rng.Intn(4) != 0)
+		} else if len(s.sets) > 0 && s.rng.Intn(2) == 0 {
+			ok = s.advance(6 * time.Second)
+		} else {
+			ok = s.dequeue()
+		}
+
+		if !ok || !s.check() {
+			return
+		}
+	}
+
+	s.g.mx.Lock()
+	defer s.g.mx.Unlock()
+
+	if len(s.g.RootContainer.Children) != 0 || len(s.g.TaskSetLogs) != 0 || len(s.g.Waits) != 0 || s.g.leases.Len() != 0 {
+		s.fail("expected an empty queue once drained, got %d containers, %d task sets, %d waits and %d leases",
+			len(s.g.RootContainer.Children), len(s.g.TaskSetLogs), len(s.g.Waits), s.g.leases.Len())
+		return
+	}
+
+	for _, st := range s.tasks {
+		if st.wait != nil && !st.received {
+			s.fail("the wait for %s was never resolved", st.id)
+			return
+		}
+	}
+}
+
+// check compares the GrooveMaster with the model, returning false if an invariant is broken
+func (s *simulation) check() bool {
+	s.t.Helper()
+
+	if !s.checkWaits() {
+		return false
+	}
+
+	s.g.mx.Lock()
+	defer s.g.mx.Unlock()
+
+	// Every container holds exactly the tasks the model expects, in order, with at most one locked task
+	seen := map[string]bool{}
+
+	var walk func(path string, tc *TaskContainer) (pending int, locked int, ok bool)
+	walk = func(path string, tc *TaskContainer) (int, int, bool) {
+		pending, locked := len(tc.Tasks), 0
+
+		if tc.Locked != (tc.LockedTask != nil) || tc.Locked != (tc.LockedBy != "") {
+			return 0, 0, s.fail("%s has locked %t, locked task %v and locked by %q", path, tc.Locked, tc.LockedTask, tc.LockedBy)
+		}
+
+		if tc.Locked {
+			locked++
+
+			st, ok := s.tasks[tc.LockedTask.ID]
+			if !ok || !st.locked || st.container != path {
+				return 0, 0, s.fail("%s has %s locked, which should not be locked", path, tc.LockedTask.ID)
+			}
+
+			set, ok := s.sets[tc.LockedBy]
+			if !ok || !contains(set.taskIDs, st.id) {
+				return 0, 0, s.fail("%s is locked by %s, which should not hold %s", path, tc.LockedBy, st.id)
+			}
+
+			if tc.LockedTask.RetryCount != st.retries {
+				return 0, 0, s.fail("%s has %d retries, expected %d", st.id, tc.LockedTask.RetryCount, st.retries)
+			}
+
+			seen[st.id] = true
+		}
+
+		queue := s.queues[path]
+		if len(queue) != len(tc.Tasks) {
+			return 0, 0, s.fail("%s has %d pending tasks, expected %d", path, len(tc.Tasks), len(queue))
+		}
+
+		for i, task := range tc.Tasks {
+			if task.ID != queue[i] {
+				return 0, 0, s.fail("%s has %s at position %d, expected %s", path, task.ID, i, queue[i])
+			}
+
+			if task.RetryCount != s.tasks[task.ID].retries {
+				return 0, 0, s.fail("%s has %d retries, expected %d", task.ID, task.RetryCount, s.tasks[task.ID].retries)
+			}
+
+			seen[task.ID] = true
+		}
+
+		for key, child := range tc.Children {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+
+			p, l, ok := walk(childPath, child)
+			if !ok {
+				return 0, 0, false
+			}
+
+			pending += p
+			locked += l
+		}
+
+		if tc.pending != pending || tc.locked != locked {
+			return 0, 0, s.fail("%q counts %d pending and %d locked, but holds %d pending and %d locked", path, tc.pending, tc.locked, pending, locked)
+		}
+
+		return pending, locked, true
+	}
+
+	if _, _, ok := walk("", s.g.RootContainer); !ok {
+		return false
+	}
+
+	// No task was lost
+	for id, st := range s.tasks {
+		if !st.done && !seen[id] {
+			return s.fail("%s is missing from the queue", id)
+		}
+	}
+
+	// Task sets and their leases match the ones the workers hold
+	if len(s.g.TaskSetLogs) != len(s.sets) || s.g.leases.Len() != len(s.sets) {
+		return s.fail("%d task sets and %d leases are in flight, expected %d", len(s.g.TaskSetLogs), s.g.leases.Len(), len(s.sets))
+	}
+
+	for id, set := range s.sets {
+		ts, ok := s.g.TaskSetLogs[id]
+		if !ok {
+			return s.fail("task set %s is missing", id)
+		}
+
+		got := append([]string(nil), ts.TaskIDs...)
+		want := append([]string(nil), set.taskIDs...)
+
+		sort.Strings(got)
+		sort.Strings(want)
+
+		if strings.Join(got, ",") != strings.Join(want, ",") || !ts.TimeoutAt.Equal(set.timeoutAt) {
+			return s.fail("task set %s holds %v until %s, expected %v until %s", id, got, ts.TimeoutAt, want, set.timeoutAt)
+		}
+	}
+
+	// Only unfinished tasks are waited on
+	for id := range s.g.Waits {
+		if st, ok := s.tasks[id]; !ok || st.done {
+			return s.fail("%s is still waited on after it finished", id)
+		}
+	}
+
+	return true
+}
+
+// checkWaits makes sure that waits are resolved as soon as their task finishes, and not before
+func (s *simulation) checkWaits() bool {
+	for _, st := range s.tasks {
+		if st.wait == nil || st.received {
+			continue
+		}
+
+		select {
+		case task := <-st.wait:
+			if !st.done {
+				return s.fail("the wait for %s was resolved before it finished", st.id)
+			}
+
+			if task.ID != st.id || task.Succeeded != st.succeeded {
+				return s.fail("the wait for %s was resolved with %s, succeeded %t, expected succeeded %t", st.id, task.ID, task.Succeeded, st.succeeded)
+			}
+
+			st.received = true
+		default:
+			if st.done {
+				return s.fail("the wait for %s was not resolved after it finished", st.id)
+			}
+		}
+	}
+
+	return true
+}
+
+// liveSet picks a task set whose worker is still running, in a repeatable order
+func (s *simulation) liveSet() *simSet {
+	var live []*simSet
+
+	for _, set := range s.sets {
+		if !set.crashed {
+			live = append(live, set)
+		}
+	}
+
+	if len(live) == 0 {
+		return nil
+	}
+
+	sort.Slice(live, func(i, j int) bool {
+		return live[i].order < live[j].order
+	})
+
+	return live[s.rng.Intn(len(live))]
+}
+
+// unfinished returns the ids of tasks that have not finished, in a repeatable order
+func (s *simulation) unfinished() []string {
+	var ids []string
+
+	for id, st := range s.tasks {
+		if !st.done {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
+
+func (s *simulation) containerLocked(container string) bool {
+	for _, set := range s.sets {
+		for _, id := range set.taskIDs {
+			if s.tasks[id].container == container {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// underPrefix reports whether a container is dequeued from by a prefix
+func underPrefix(container string, prefix string) bool {
+	return container == prefix || hasPrefix(container, prefix)
+}
+
+func containerOf(taskID string) string {
+	return taskID[:strings.LastIndex(taskID, ".")]
+}
+
+func contains(ids []string, id string) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
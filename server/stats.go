@@ -0,0 +1,96 @@
+package server
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// rateWindow is roughly how far back a rate looks, older events have an exponentially decaying weight
+const rateWindow = time.Minute
+
+// rate is an exponentially weighted moving average of events per second
+type rate struct {
+	value   float64
+	updated time.Time
+}
+
+func (r *rate) add(now time.Time, n float64) {
+	r.value = r.at(now) + n/rateWindow.Seconds()
+	r.updated = now
+}
+
+// at returns the rate as of now
+func (r *rate) at(now time.Time) float64 {
+	if r.updated.IsZero() {
+		return 0
+	}
+
+	return r.value * math.Exp(-now.Sub(r.updated).Seconds()/rateWindow.Seconds())
+}
+
+// Stats returns statistics for the container at prefix, along with its descendants that are depth levels
+// below it. The second return value is false if there are no tasks under the prefix
+func (g *GrooveMaster) Stats(prefix string, depth int) (groove.PrefixStats, []groove.PrefixStats, bool) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	tc := g.RootContainer
+
+	if prefix != "" {
+		tc, _ = g.RootContainer.GetChildContainer(prefix)
+		if tc == nil {
+			return groove.PrefixStats{}, nil, false
+		}
+	}
+
+	now := g.clock.Now()
+
+	children := []groove.PrefixStats{}
+
+	var walk func(tc *TaskContainer, path string, remaining int)
+	walk = func(tc *TaskContainer, path string, remaining int) {
+		if remaining == 0 {
+			children = append(children, tc.stats(path, now))
+			return
+		}
+
+		for k, child := range tc.Children {
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+
+			walk(child, childPath, remaining-1)
+		}
+	}
+
+	if depth > 0 {
+		walk(tc, prefix, depth)
+	}
+
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Prefix < children[j].Prefix
+	})
+
+	return tc.stats(prefix, now), children, true
+}
+
+// stats is not safe to be called on it's own. The caller must ensure thread safety
+func (t *TaskContainer) stats(prefix string, now time.Time) groove.PrefixStats {
+	ps := groove.PrefixStats{
+		Prefix:           prefix,
+		Pending:          t.pending,
+		Locked:           t.locked,
+		InFlightTaskSets: len(t.taskSets),
+		Throughput:       t.throughput.at(now),
+	}
+
+	if oldest := t.oldestPending(); !oldest.IsZero() {
+		ps.OldestPending = &oldest
+	}
+
+	return ps
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// countTree walks a container to count what its counters should be
+func countTree(tc *TaskContainer) (pending int, locked int, taskSets map[string]bool) {
+	pending = len(tc.Tasks)
+	taskSets = map[string]bool{}
+
+	if tc.Locked {
+		locked++
+		taskSets[tc.LockedBy] = true
+	}
+
+	for _, child := range tc.Children {
+		p, l, ts := countTree(child)
+
+		pending += p
+		locked += l
+
+		for id := range ts {
+			taskSets[id] = true
+		}
+	}
+
+	return pending, locked, taskSets
+}
+
+func checkCounters(t *testing.T, tc *TaskContainer, path string) {
+	pending, locked, taskSets := countTree(tc)
+
+	if tc.pending != pending || tc.locked != locked || len(tc.taskSets) != len(taskSets) {
+		t.Errorf("counters for %q are pending=%d locked=%d task sets=%d, expected %d %d %d",
+			path, tc.pending, tc.locked, len(tc.taskSets), pending, locked, len(taskSets))
+	}
+
+	for k, child := range tc.Children {
+		checkCounters(t, child, path+"."+k)
+	}
+}
+
+func TestGrooveMaster_Stats(t *testing.T) {
+	g := New()
+
+	var tasks []groove.Task
+
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 4; j++ {
+			tasks = append(tasks, groove.Task{ID: fmt.Sprintf("stats.%d.%d", i, j), RetryThreshold: 1})
+		}
+	}
+
+	tasks = append(tasks, groove.Task{ID: "stats.0.nested.0", RetryThreshold: 1})
+
+	g.Enqueue(tasks)
+
+	first := g.Dequeue(3, "stats", 10*time.Second)
+	second := g.Dequeue(3, "stats", 10*time.Second)
+
+	checkCounters(t, g.RootContainer, "")
+
+	err := g.Ack(first.ID, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = g.Nack(second.ID, "failed")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	checkCounters(t, g.RootContainer, "")
+
+	// Only one task per container can be locked at a time
+	third := g.Dequeue(2, "stats.1", 10*time.Second)
+
+	stats, children, ok := g.Stats("stats", 1)
+	if !ok {
+		t.Error("expected stats to exist")
+		return
+	}
+
+	if stats.Pending != 21-3-1 || stats.Locked != 1 || stats.InFlightTaskSets != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	if stats.OldestPending == nil || stats.Throughput <= 0 {
+		t.Errorf("expected an oldest pending time and throughput, got %+v", stats)
+	}
+
+	if len(children) != 5 || children[1].Prefix != "stats.1" || children[1].Locked != 1 {
+		t.Errorf("unexpected child stats %+v", children)
+	}
+
+	err = g.Ack(third.ID, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	checkCounters(t, g.RootContainer, "")
+
+	if _, _, ok := g.Stats("missing", 1); ok {
+		t.Error("expected stats for a missing prefix to not exist")
+	}
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// Storage keeps snapshots of the queue so that it survives restarts
+type Storage interface {
+	// Load returns the last saved snapshot, or nil if nothing has been saved yet
+	Load() (*Snapshot, error)
+	Save(snapshot *Snapshot) error
+}
+
+// Snapshot is the durable state of a GrooveMaster. Task sets that are in flight are not part of a snapshot,
+// their tasks are stored as pending so that they are dequeued again after a restart
+type Snapshot struct {
+	Time        time.Time           `json:"time"`
+	Tasks       []StoredTask        `json:"tasks"` // In the order they would be dequeued from each container
+	Paused      []string            `json:"paused,omitempty"`
+	DeadLetters []groove.DeadLetter `json:"dead_letters,omitempty"`
+	Batches     []StoredBatch       `json:"batches,omitempty"` // Sorted by id
+
+	Webhooks []groove.WebhookSubscription `json:"webhooks,omitempty"` // Sorted by id, including their secrets
+
+	// Only policies changed since the config was loaded are kept, so that changes to the config apply after a restart
+	Policies        map[string]groove.Policy `json:"policies,omitempty"`         // Set with SetPolicy, keyed by prefix
+	RemovedPolicies []string                 `json:"removed_policies,omitempty"` // Removed with RemovePolicy, sorted
+}
+
+// StoredTask is a task along with the state groove keeps about it that is not sent to clients
+type StoredTask struct {
+	groove.Task
+	RetryCount int `json:"retry_count"`
+}
+
+// StoredBatch is a batch along with the results that are kept for its callback
+type StoredBatch struct {
+	groove.Batch
+	Results map[string]interface{}   `json:"results,omitempty"`
+	Errors  map[string][]interface{} `json:"errors,omitempty"`
+
+	Prefixes []string `json:"prefixes,omitempty"` // The prefixes of the tasks in the batch and of its callback
+}
+
+// FileStorage stores snapshots as a json file
+type FileStorage struct {
+	Path string
+}
+
+func NewFileStorage(path string) *FileStorage {
+	return &FileStorage{Path: path}
+}
+
+func (f *FileStorage) Load() (*Snapshot, error) {
+	b, err := os.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var s Snapshot
+
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// Save writes the snapshot to a temporary file before moving it into place, so a crash never leaves a partial snapshot
+func (f *FileStorage) Save(snapshot *Snapshot) error {
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+// Snapshot captures the durable state of the GrooveMaster
+func (g *GrooveMaster) Snapshot() *Snapshot {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	s := &Snapshot{
+		Time:        g.clock.Now(),
+		Tasks:       []StoredTask{},
+		DeadLetters: append([]groove.DeadLetter(nil), g.deadLetters...),
+	}
+
+	for prefix, p := range g.policyChanges {
+		if p == nil {
+			s.RemovedPolicies = append(s.RemovedPolicies, prefix)
+			continue
+		}
+
+		if s.Policies == nil {
+			s.Policies = map[string]groove.Policy{}
+		}
+
+		s.Policies[prefix] = *p
+	}
+
+	sort.Strings(s.RemovedPolicies)
+
+	for p := range g.paused {
+		s.Paused = append(s.Paused, p)
+	}
+
+	sort.Strings(s.Paused)
+
+	// The results are copied, as the snapshot is saved after the lock is released
+	for _, b := range g.batches {
+		sb := StoredBatch{Batch: b.Batch, Prefixes: b.sortedPrefixes()}
+
+		if len(b.results) > 0 {
+			sb.Results = make(map[string]interface{}, len(b.results))
+			for id, r := range b.results {
+				sb.Results[id] = r
+			}
+		}
+
+		if len(b.errors) > 0 {
+			sb.Errors = make(map[string][]interface{}, len(b.errors))
+			for id, e := range b.errors {
+				sb.Errors[id] = e
+			}
+		}
+
+		s.Batches = append(s.Batches, sb)
+	}
+
+	sort.Slice(s.Batches, func(i, j int) bool {
+		return s.Batches[i].ID < s.Batches[j].ID
+	})
+
+	g.webhooks.mx.RLock()
+
+	for _, sub := range g.webhooks.subscriptions {
+		s.Webhooks = append(s.Webhooks, sub)
+	}
+
+	g.webhooks.mx.RUnlock()
+
+	sort.Slice(s.Webhooks, func(i, j int) bool {
+		return s.Webhooks[i].ID < s.Webhooks[j].ID
+	})
+
+	var walk func(tc *TaskContainer)
+	walk = func(tc *TaskContainer) {
+		// The locked task goes first, as it would be requeued at the front if its task set failed
+		if tc.Locked {
+			s.Tasks = append(s.Tasks, StoredTask{Task: *tc.LockedTask, RetryCount: tc.LockedTask.RetryCount})
+		}
+
+		for _, t := range tc.Tasks {
+			s.Tasks = append(s.Tasks, StoredTask{Task: t, RetryCount: t.RetryCount})
+		}
+
+		keys := make([]string, 0, len(tc.Children))
+		for k := range tc.Children {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			walk(tc.Children[k])
+		}
+	}
+
+	walk(g.RootContainer)
+
+	return s
+}
+
+// Restore adds the contents of a snapshot to the GrooveMaster
+func (g *GrooveMaster) Restore(s *Snapshot) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	for _, p := range s.Paused {
+		g.paused[p] = true
+	}
+
+	g.webhooks.mx.Lock()
+
+	for _, sub := range s.Webhooks {
+		g.webhooks.subscriptions[sub.ID] = sub
+	}
+
+	g.webhooks.mx.Unlock()
+
+	for _, sb := range s.Batches {
+		b := &batchLog{Batch: sb.Batch, results: sb.Results, errors: sb.Errors, prefixes: map[string]bool{}}
+
+		for _, p := range sb.Prefixes {
+			b.prefixes[p] = true
+		}
+
+		// Only batches that are still pending need somewhere to keep results
+		if !b.Complete {
+			if b.results == nil {
+				b.results = map[string]interface{}{}
+			}
+
+			if b.errors == nil {
+				b.errors = map[string][]interface{}{}
+			}
+		}
+
+		g.batches[b.ID] = b
+	}
+
+	// Policy changes are applied on top of the policies of the config
+	for prefix, p := range s.Policies {
+		p := p
+
+		g.policies.set(prefix, p)
+		g.policyChanges[prefix] = &p
+	}
+
+	for _, prefix := range s.RemovedPolicies {
+		g.policies.remove(prefix)
+		g.policyChanges[prefix] = nil
+	}
+
+	for _, st := range s.Tasks {
+		t := st.Task
+		t.RetryCount = st.RetryCount
+
+		g.putTask(t)
+	}
+
+	g.deadLetters = append(g.deadLetters, s.DeadLetters...)
+}
+
+// save writes a snapshot to storage, if there is any
+func (g *GrooveMaster) save() error {
+	if g.storage == nil {
+		return nil
+	}
+
+	err := g.storage.Save(g.Snapshot())
+	g.recordSave(err)
+
+	return err
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func TestGrooveMaster_Storage(t *testing.T) {
+	storage := NewFileStorage(filepath.Join(t.TempDir(), "groove.json"))
+
+	g, err := Open(WithStorage(storage, time.Hour))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	g.Enqueue([]groove.Task{
+		{ID: "storage.a.1", RetryThreshold: 3},
+		{ID: "storage.a.2", Data: map[string]interface{}{"n": 2.0}},
+		{ID: "storage.b.1"},
+	})
+
+	g.Pause("storage.b")
+
+	ts := g.Dequeue(1, "storage.a", time.Minute)
+	if ts == nil {
+		t.Error("expected a task set")
+		return
+	}
+
+	// Fail the first task once so that it has a retry count to restore
+	err = g.Nack(ts.ID, "failed")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if g.Dequeue(1, "storage.a", time.Minute) == nil {
+		t.Error("expected a task set")
+		return
+	}
+
+	sub, err := g.AddWebhook(groove.WebhookSubscription{
+		URL:    "http://127.0.0.1:1/hook",
+		Events: []groove.EventType{groove.EventDeadLettered},
+		Secret: "secret",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = g.Close()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	restored, err := Open(WithStorage(storage, time.Hour))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	defer restored.Close()
+
+	checkCounters(t, restored.RootContainer, "")
+
+	if paused := restored.Paused(); len(paused) != 1 || paused[0] != "storage.b" {
+		t.Errorf("expected storage.b to still be paused, got %v", paused)
+		return
+	}
+
+	if hook := restored.webhooks.subscriptions[sub.ID]; hook.URL != sub.URL || hook.Secret != "secret" {
+		t.Errorf("expected the webhook to be restored with its secret, got %+v", hook)
+		return
+	}
+
+	// The task that was in flight when groove closed is dequeued first
+	ts = restored.Dequeue(2, "storage.a", time.Minute)
+	if ts == nil || len(ts.Tasks) != 1 || ts.Tasks[0].ID != "storage.a.1" || ts.Tasks[0].RetryCount != 1 {
+		t.Errorf("expected storage.a.1 to be restored with its retry count, got %+v", ts)
+		return
+	}
+
+	err = restored.Ack(ts.ID, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ts = restored.Dequeue(2, "storage.a", time.Minute)
+	if ts == nil || len(ts.Tasks) != 1 || ts.Tasks[0].ID != "storage.a.2" || ts.Tasks[0].Data.(map[string]interface{})["n"] != 2.0 {
+		t.Errorf("expected storage.a.2 to be restored with its data, got %+v", ts)
+		return
+	}
+}
+
+func TestGrooveMaster_Close(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+
+	g := New(WithClock(clock))
+
+	g.Enqueue([]groove.Task{{ID: "close.a.1"}})
+
+	// The task set can only expire once the test moves the clock, which it does after closing
+	if g.Dequeue(1, "close", time.Second) == nil {
+		t.Error("expected a task set")
+		return
+	}
+
+	err := g.Close()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Nothing times out task sets once the background loop has stopped
+	clock.advance(time.Minute)
+	time.Sleep(300 * time.Millisecond)
+
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	if len(g.TaskSetLogs) != 1 {
+		t.Errorf("expected the task set to be left alone after closing, got %d task sets", len(g.TaskSetLogs))
+	}
+}
+
+func TestGrooveMaster_StorageBatches(t *testing.T) {
+	storage := NewFileStorage(filepath.Join(t.TempDir(), "groove.json"))
+	clock := &testClock{now: time.Now()}
+	enqueuedAt := clock.Now()
+
+	g, err := Open(WithStorage(storage, time.Hour), WithClock(clock))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = g.EnqueueBatch("restart", &groove.BatchCallback{Prefix: "storage.done"}, []groove.Task{
+		{ID: "storage.c.1"},
+		{ID: "storage.d.1"},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ts := g.Dequeue(1, "storage.c", time.Minute)
+	if ts == nil {
+		t.Error("expected a task set")
+		return
+	}
+
+	err = g.Ack(ts.ID, "first")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = g.Close()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	clock.advance(time.Hour)
+
+	restored, err := Open(WithStorage(storage, time.Hour), WithClock(clock))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	defer restored.Close()
+
+	if b, ok := restored.Batch("restart"); !ok || b.Pending != 1 || b.Succeeded != 1 {
+		t.Errorf("expected the batch to be restored with one task pending, got %+v", b)
+		return
+	}
+
+	ts = restored.Dequeue(1, "storage.d", time.Minute)
+	if ts == nil || !ts.Tasks[0].EnqueuedAt.Equal(enqueuedAt) {
+		t.Errorf("expected storage.d.1 to keep the time it was enqueued, got %+v", ts)
+		return
+	}
+
+	err = restored.Ack(ts.ID, "second")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ts = restored.Dequeue(1, "storage.done", time.Minute)
+	if ts == nil {
+		t.Error("expected the batch callback to be enqueued")
+		return
+	}
+
+	result := ts.Tasks[0].Data.(groove.BatchResult)
+	if result.Succeeded != 2 || result.Results["storage.c.1"] != "first" || result.Results["storage.d.1"] != "second" {
+		t.Errorf("expected the callback to have the results from before and after the restart, got %+v", result)
+	}
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// certCheckInterval is how often the certificate files are checked for changes
+const certCheckInterval = 10 * time.Second
+
+// TLSOptions are the files used to serve groove over TLS
+type TLSOptions struct {
+	CertFile string `json:"cert_file,omitempty"` // The server certificate, followed by any intermediates
+	KeyFile  string `json:"key_file,omitempty"`  // The key of the server certificate
+
+	// ClientCAFile turns on mutual TLS. Clients can then present a certificate signed by one of these CAs,
+	// which authenticates them in place of an api key. Clients without a certificate can still connect
+	ClientCAFile string `json:"client_ca_file,omitempty"`
+}
+
+// CertReloader serves TLS using certificate files that can be replaced while groove is running.
+// The files are checked for changes at most every 10 seconds, and are reloaded when they change
+type CertReloader struct {
+	opts TLSOptions
+
+	mx        sync.Mutex
+	config    *tls.Config
+	modTimes  []time.Time
+	checkedAt time.Time
+}
+
+// NewCertReloader loads the certificate files, failing if they cannot be used
+func NewCertReloader(opts TLSOptions) (*CertReloader, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, errors.New("tls needs a certificate file and a key file")
+	}
+
+	r := &CertReloader{opts: opts}
+
+	err := r.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// TLSConfig returns a tls config for an http.Server, every connection uses the latest certificates
+func (r *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current(), nil
+		},
+	}
+}
+
+// Reload loads the certificate files straight away. Connections that are already open keep their certificates
+func (r *CertReloader) Reload() error {
+	modTimes, err := r.statFiles()
+	if err != nil {
+		return err
+	}
+
+	config, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	r.config = config
+	r.modTimes = modTimes
+	r.checkedAt = time.Now()
+
+	return nil
+}
+
+// current returns the tls config to use, reloading it first if the files have changed.
+// A change that cannot be loaded is ignored, so a half written file does not stop groove serving
+func (r *CertReloader) current() *tls.Config {
+	r.mx.Lock()
+
+	if time.Since(r.checkedAt) < certCheckInterval {
+		defer r.mx.Unlock()
+		return r.config
+	}
+
+	r.checkedAt = time.Now()
+	config, previous := r.config, r.modTimes
+
+	r.mx.Unlock()
+
+	modTimes, err := r.statFiles()
+	if err != nil || equalTimes(modTimes, previous) {
+		return config
+	}
+
+	if r.Reload() != nil {
+		return config
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	return r.config
+}
+
+func (r *CertReloader) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tls certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"}, // This config replaces the server's own, which is where http2 is offered
+	}
+
+	if r.opts.ClientCAFile != "" {
+		pool, err := loadCertPool(r.opts.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.ClientCAs = pool
+		// Api keys and the health checks work without a certificate, so one is only checked if it is given
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return config, nil
+}
+
+func (r *CertReloader) statFiles() ([]time.Time, error) {
+	var times []time.Time
+
+	for _, path := range []string{r.opts.CertFile, r.opts.KeyFile, r.opts.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		times = append(times, info.ModTime())
+	}
+
+	return times, nil
+}
+
+func equalTimes(a []time.Time, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// loadCertPool reads a file of PEM encoded certificates
+func loadCertPool(path string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+
+	return pool, nil
+}
+
+// certificateIdentities lists the names that a client certificate can be matched to an api key by:
+// its common name, then its dns names, uris and email addresses
+func certificateIdentities(cert *x509.Certificate) []string {
+	var ids []string
+
+	if cert.Subject.CommonName != "" {
+		ids = append(ids, cert.Subject.CommonName)
+	}
+
+	ids = append(ids, cert.DNSNames...)
+
+	for _, u := range cert.URIs {
+		ids = append(ids, u.String())
+	}
+
+	return append(ids, cert.EmailAddresses...)
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+// testCert creates a certificate signed by parent, or a self signed CA if parent is nil
+func testCert(t *testing.T, serial int64, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, b []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	ca, caKey, caPEM, _ := testCert(t, 1, "groove test ca", nil, nil)
+	_, _, serverPEM, serverKeyPEM := testCert(t, 2, "groove", ca, caKey)
+	_, _, clientPEM, clientKeyPEM := testCert(t, 3, "worker-1", ca, caKey)
+
+	writeFile(t, path("ca.pem"), caPEM)
+	writeFile(t, path("server.pem"), serverPEM)
+	writeFile(t, path("server-key.pem"), serverKeyPEM)
+	writeFile(t, path("client.pem"), clientPEM)
+	writeFile(t, path("client-key.pem"), clientKeyPEM)
+
+	certs, err := NewCertReloader(TLSOptions{CertFile: path("server.pem"), KeyFile: path("server-key.pem"), ClientCAFile: path("ca.pem")})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	g := New(WithAPIKeys([]groove.APIKey{
+		{ID: "worker", ClientCertificate: "worker-1", Permissions: []groove.Permission{groove.PermissionRead}},
+	}))
+	defer g.Close()
+
+	srv := httptest.NewUnstartedServer(g.Handler())
+	srv.TLS = certs.TLSConfig()
+	srv.StartTLS()
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	clientTLS, err := groove.LoadTLSConfig(path("ca.pem"), path("client.pem"), path("client-key.pem"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	worker := groove.New(srv.URL, groove.WithTLSConfig(clientTLS))
+
+	// The client certificate authenticates the worker key
+	_, err = worker.Stats(ctx, "", 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = worker.Pause(ctx, "tls")
+	if err == nil || !strings.Contains(err.Error(), "does not have the admin permission") {
+		t.Errorf("expected the worker key to be limited to its permissions, got %v", err)
+		return
+	}
+
+	// A key without a secret cannot be used to sign requests
+	_, err = groove.New(srv.URL, groove.WithTLSConfig(clientTLS), groove.WithSignedRequests("worker", "")).Stats(ctx, "", 1)
+	if err == nil || !strings.Contains(err.Error(), "invalid api key") {
+		t.Errorf("expected a signature without a secret to be rejected, got %v", err)
+		return
+	}
+
+	anonTLS, err := groove.LoadTLSConfig(path("ca.pem"), "", "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	anon := groove.New(srv.URL, groove.WithTLSConfig(anonTLS))
+
+	// Clients without a certificate can connect, but need an api key for anything but the health checks
+	_, err = anon.Stats(ctx, "", 1)
+	if err == nil || !strings.Contains(err.Error(), "api key is required") {
+		t.Errorf("expected a client without a certificate or key to be rejected, got %v", err)
+		return
+	}
+
+	_, err = anon.Health(ctx)
+	if err != nil {
+		t.Errorf("expected the health check to work without a certificate, got %v", err)
+		return
+	}
+
+	h2, err := tls.Dial("tcp", srv.Listener.Addr().String(), &tls.Config{RootCAs: anonTLS.RootCAs, NextProtos: []string{"h2", "http/1.1"}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	defer h2.Close()
+
+	if p := h2.ConnectionState().NegotiatedProtocol; p != "h2" {
+		t.Errorf("expected http2 to be offered, got %q", p)
+		return
+	}
+
+	serial := func() int64 {
+		conn, err := tls.Dial("tcp", srv.Listener.Addr().String(), clientTLS)
+		if err != nil {
+			t.Error(err)
+			return 0
+		}
+
+		defer conn.Close()
+
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	if s := serial(); s != 2 {
+		t.Errorf("expected the server certificate to have serial 2, got %d", s)
+		return
+	}
+
+	// A replaced certificate is used by new connections once reloaded
+	_, _, serverPEM, serverKeyPEM = testCert(t, 4, "groove", ca, caKey)
+	writeFile(t, path("server.pem"), serverPEM)
+	writeFile(t, path("server-key.pem"), serverKeyPEM)
+
+	err = certs.Reload()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if s := serial(); s != 4 {
+		t.Errorf("expected the reloaded server certificate to have serial 4, got %d", s)
+	}
+}
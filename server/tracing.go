@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+var tracer = otel.Tracer(groove.TracerName)
+
+// SetupTracing exports spans over otlp/http when OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set,
+// the exporter is configured with the standard OTEL_* env vars. The returned func flushes any buffered spans
+func SetupTracing(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(groove.Propagator)
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exp, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.Default()
+
+	if os.Getenv("OTEL_SERVICE_NAME") == "" {
+		res, err = resource.Merge(res, resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("groove")))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+// TraceMiddleware starts a server span for every request, continuing any trace context sent by the client
+func TraceMiddleware(c *gin.Context) {
+	ctx := groove.Propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+
+	ctx, span := tracer.Start(ctx, c.Request.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(c.Request.Method),
+			semconv.HTTPRoute(route),
+			semconv.URLPath(c.Request.URL.Path),
+		),
+	)
+	defer span.End()
+
+	c.Request = c.Request.WithContext(ctx)
+
+	c.Next()
+
+	status := c.Writer.Status()
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+
+	if status >= 500 {
+		span.SetStatus(codes.Error, "")
+	}
+}
+
+// injectTraceContext gives tasks that do not carry a trace context the trace context of ctx
+func injectTraceContext(ctx context.Context, tasks []groove.Task) {
+	for i := range tasks {
+		groove.InjectTraceContext(ctx, &tasks[i])
+	}
+}
+
+// taskLinks links a span to the traces that enqueued each of the tasks
+func taskLinks(tasks []groove.Task) trace.SpanStartOption {
+	var links []trace.Link
+
+	for _, t := range tasks {
+		sc := trace.SpanContextFromContext(t.Context(context.Background()))
+
+		if sc.IsValid() {
+			links = append(links, trace.Link{
+				SpanContext: sc,
+				Attributes:  []attribute.KeyValue{attribute.String("groove.task_id", t.ID)},
+			})
+		}
+	}
+
+	return trace.WithLinks(links...)
+}
+
+// endSpan records err on the span (if not nil) and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
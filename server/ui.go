@@ -0,0 +1,16 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed ui/index.html
+var uiIndex []byte
+
+// hUI serves the admin web ui, which is built entirely on top of the http api
+func hUI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", uiIndex)
+}
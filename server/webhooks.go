@@ -0,0 +1,281 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+const (
+	webhookQueueSize   = 10000
+	webhookWorkers     = 4
+	webhookMaxAttempts = 5
+)
+
+// webhookDelivery is a single event that needs to be sent to a single subscription
+type webhookDelivery struct {
+	id           string
+	subscription groove.WebhookSubscription
+	event        groove.Event
+}
+
+// webhookDispatcher delivers events to webhook subscriptions in the background, so that slow
+// or failing receivers never hold up the queue
+type webhookDispatcher struct {
+	mx            sync.RWMutex
+	subscriptions map[string]groove.WebhookSubscription
+
+	queue   chan webhookDelivery
+	closed  bool           // Set once the queue is closed, protected by mx
+	done    chan struct{}  // Closed by stop, cutting short any wait before a retry
+	workers sync.WaitGroup // Delivery goroutines, stop waits for them to finish
+	client  *http.Client
+	clock   Clock
+	backoff time.Duration // The wait before the first retry, doubled after each failed attempt
+	dropped uint64        // Deliveries dropped because the queue was full
+	logger  *slog.Logger  // Protected by mx, as it can be replaced while deliveries are running
+}
+
+func newWebhookDispatcher() *webhookDispatcher {
+	return &webhookDispatcher{
+		subscriptions: map[string]groove.WebhookSubscription{},
+		queue:         make(chan webhookDelivery, webhookQueueSize),
+		done:          make(chan struct{}),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		clock:   realClock{},
+		backoff: 500 * time.Millisecond,
+		logger:  slog.Default(),
+	}
+}
+
+func (d *webhookDispatcher) start() {
+	for i := 0; i < webhookWorkers; i++ {
+		d.workers.Add(1)
+
+		go func() {
+			defer d.workers.Done()
+
+			for del := range d.queue {
+				d.deliver(del)
+			}
+		}()
+	}
+}
+
+// stop closes the queue and waits for the workers to finish. Deliveries that were already queued are
+// still attempted, but failed deliveries are no longer retried
+func (d *webhookDispatcher) stop() {
+	d.mx.Lock()
+
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+		close(d.done)
+	}
+
+	d.mx.Unlock()
+
+	d.workers.Wait()
+}
+
+// publish queues deliveries of an event to every matching subscription, it never blocks
+func (d *webhookDispatcher) publish(e groove.Event) {
+	d.mx.RLock()
+	defer d.mx.RUnlock()
+
+	if d.closed {
+		return
+	}
+
+	for _, sub := range d.subscriptions {
+		if !eventMatches(e, sub.Prefix, sub.Events) {
+			continue
+		}
+
+		select {
+		case d.queue <- webhookDelivery{id: uuid.Must(uuid.NewRandom()).String(), subscription: sub, event: e}:
+		default:
+			atomic.AddUint64(&d.dropped, 1)
+		}
+	}
+}
+
+// deliver sends a delivery, retrying with exponential backoff until it succeeds, runs out of attempts
+// or the dispatcher is stopped
+func (d *webhookDispatcher) deliver(del webhookDelivery) {
+	body, err := json.Marshal(del.event)
+	if err != nil {
+		return
+	}
+
+	backoff := d.backoff
+
+	attempt := 1
+
+	for ; ; attempt++ {
+		err = d.send(del, body)
+		if err == nil {
+			return
+		}
+
+		if attempt == webhookMaxAttempts || !d.wait(backoff) {
+			break
+		}
+
+		backoff *= 2
+	}
+
+	d.mx.RLock()
+	logger := d.logger
+	d.mx.RUnlock()
+
+	logger.Warn("webhook delivery failed",
+		slog.String("webhook_id", del.subscription.ID),
+		slog.String("delivery_id", del.id),
+		slog.String("task_id", del.event.TaskID),
+		slog.String("event", string(del.event.Type)),
+		slog.Int("attempts", attempt),
+		slog.String("error", err.Error()),
+	)
+}
+
+// wait sleeps before a retry, returning false if the dispatcher was stopped in the meantime
+func (d *webhookDispatcher) wait(backoff time.Duration) bool {
+	timer := d.clock.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return true
+	case <-d.done:
+		return false
+	}
+}
+
+// setLogger replaces the logger used to report failed deliveries
+func (d *webhookDispatcher) setLogger(logger *slog.Logger) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	d.logger = logger
+}
+
+func (d *webhookDispatcher) send(del webhookDelivery, body []byte) error {
+	req, err := http.NewRequest("POST", del.subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(groove.WebhookEventHeader, string(del.event.Type))
+	req.Header.Set(groove.WebhookDeliveryHeader, del.id)
+
+	if del.subscription.Secret != "" {
+		req.Header.Set(groove.WebhookSignatureHeader, groove.SignWebhook(del.subscription.Secret, body))
+	}
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	_ = res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// AddWebhook subscribes a url to task lifecycle events, the created subscription is returned with its id
+func (g *GrooveMaster) AddWebhook(sub groove.WebhookSubscription) (groove.WebhookSubscription, error) {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return sub, errors.New("webhook url must be an absolute http or https url")
+	}
+
+	for _, et := range sub.Events {
+		if !validEventType(et) {
+			return sub, fmt.Errorf("unknown event type %q", et)
+		}
+	}
+
+	sub.ID = uuid.Must(uuid.NewRandom()).String()
+
+	g.webhooks.mx.Lock()
+	g.webhooks.subscriptions[sub.ID] = sub
+	g.webhooks.mx.Unlock()
+
+	return sub, nil
+}
+
+// Webhooks lists the current webhook subscriptions, secrets are not included
+func (g *GrooveMaster) Webhooks() []groove.WebhookSubscription {
+	g.webhooks.mx.RLock()
+	defer g.webhooks.mx.RUnlock()
+
+	subs := []groove.WebhookSubscription{}
+
+	for _, sub := range g.webhooks.subscriptions {
+		sub.Secret = ""
+		subs = append(subs, sub)
+	}
+
+	return subs
+}
+
+// RemoveWebhook deletes a webhook subscription, returning false if it did not exist
+func (g *GrooveMaster) RemoveWebhook(id string) bool {
+	g.webhooks.mx.Lock()
+	defer g.webhooks.mx.Unlock()
+
+	_, ok := g.webhooks.subscriptions[id]
+	delete(g.webhooks.subscriptions, id)
+
+	return ok
+}
+
+// eventMatches checks if an event is for a task under prefix, and is one of the given types.
+// An empty prefix or empty list of types matches everything
+func eventMatches(e groove.Event, prefix string, types []groove.EventType) bool {
+	if prefix != "" && e.TaskID != prefix && !strings.HasPrefix(e.TaskID, prefix+".") {
+		return false
+	}
+
+	if len(types) == 0 {
+		return true
+	}
+
+	for _, t := range types {
+		if t == e.Type {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validEventType(et groove.EventType) bool {
+	for _, t := range groove.EventTypes {
+		if t == et {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	groove "github.com/datomar-labs-inc/groove/common"
+)
+
+func TestGrooveMaster_Webhooks(t *testing.T) {
+	g := New()
+	g.webhooks.backoff = time.Millisecond
+
+	var attempts uint32
+
+	events := make(chan groove.Event, 10)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		if !groove.VerifyWebhook("secret", body, r.Header.Get(groove.WebhookSignatureHeader)) {
+			t.Error("webhook signature did not verify")
+		}
+
+		// Fail the first delivery to make sure it gets retried
+		if atomic.AddUint32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var e groove.Event
+
+		err := json.Unmarshal(body, &e)
+		if err != nil {
+			t.Error(err)
+		}
+
+		events <- e
+	}))
+	defer srv.Close()
+
+	_, err := g.AddWebhook(groove.WebhookSubscription{
+		URL:    srv.URL,
+		Prefix: "hooks",
+		Events: []groove.EventType{groove.EventAcked},
+		Secret: "secret",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	g.Enqueue([]groove.Task{{ID: "hooks.a"}, {ID: "other.a"}})
+
+	dq := g.Dequeue(2, "", 10*time.Second)
+	if dq == nil {
+		t.Error("expected a task set")
+		return
+	}
+
+	err = g.Ack(dq.ID, "result")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	select {
+	case e := <-events:
+		if e.Type != groove.EventAcked || e.TaskID != "hooks.a" || e.TaskSetID != dq.ID {
+			t.Errorf("unexpected event %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("timed out waiting for webhook delivery")
+		return
+	}
+
+	select {
+	case e := <-events:
+		t.Errorf("unexpected extra event %+v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if atomic.LoadUint32(&attempts) != 2 {
+		t.Errorf("expected 2 delivery attempts, got %d", attempts)
+	}
+}
+
+func TestGrooveMaster_CloseStopsWebhookRetries(t *testing.T) {
+	g := New()
+	g.webhooks.backoff = time.Hour
+
+	attempted := make(chan struct{}, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		select {
+		case attempted <- struct{}{}:
+		default:
+		}
+	}))
+	defer srv.Close()
+
+	_, err := g.AddWebhook(groove.WebhookSubscription{URL: srv.URL, Events: []groove.EventType{groove.EventEnqueued}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	g.Enqueue([]groove.Task{{ID: "hooks.a"}})
+
+	select {
+	case <-attempted:
+	case <-time.After(5 * time.Second):
+		t.Error("timed out waiting for a webhook delivery")
+		return
+	}
+
+	closed := make(chan error)
+
+	go func() {
+		closed <- g.Close()
+	}()
+
+	// The failed delivery is waiting an hour to be retried, closing must not wait for it
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("close waited for the webhook retry")
+	}
+}